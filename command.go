@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/service/article_service"
+	"github.com/miaozhang/webservice/service/auth_service"
+	"github.com/miaozhang/webservice/settings"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"migrate": {
+		usage: "create or update the tables managed by the application",
+		run: func(args []string) error {
+			return models.Migrate()
+		},
+	},
+	"migrate-passwords": {
+		usage: "hash every password still stored in plaintext in the auth table",
+		run: func(args []string) error {
+			count, err := auth_service.MigratePasswords()
+			if err != nil {
+				return err
+			}
+
+			log.Printf("migrate-passwords: %d password(s) hashed", count)
+			return nil
+		},
+	},
+	"publish-due": {
+		usage: "publish the scheduled articles whose publish_at has passed",
+		run: func(args []string) error {
+			published, err := article_service.PublishDue()
+			if err != nil {
+				return err
+			}
+
+			log.Printf("publish-due: %d article(s) published", published)
+			return nil
+		},
+	},
+	"set-role": {
+		usage: "change the role of the user with the given id (admin, editor, author, reader)",
+		run: func(args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("usage: set-role <user id> <role>")
+			}
+			id, err := strconv.Atoi(args[0])
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid user id %q", args[0])
+			}
+
+			if err := auth_service.SetRole(id, args[1]); err != nil {
+				return err
+			}
+
+			log.Printf("set-role: user %d is now %s", id, args[1])
+			return nil
+		},
+	},
+	"revoke-tokens": {
+		usage: "revoke every access and refresh token and API key of the user with the given id",
+		run: func(args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("usage: revoke-tokens <user id>")
+			}
+			id, err := strconv.Atoi(args[0])
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid user id %q", args[0])
+			}
+
+			if err := auth_service.RevokeUserTokens(id, true); err != nil {
+				return err
+			}
+
+			if settings.AppSetting.TokenDenylist != "redis" {
+				log.Printf("revoke-tokens: tokens of user %d revoked, running servers deny its access tokens within %s", id, auth_service.RevocationSyncInterval)
+				return nil
+			}
+			log.Printf("revoke-tokens: tokens of user %d revoked", id)
+			return nil
+		},
+	},
+}
+
+// runCommand run the command named by args[0] and exit
+func runCommand(args []string) {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\ncommands:\n", args[0])
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+		}
+		os.Exit(2)
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/astaxie/beego/validation"
 	"github.com/gin-gonic/gin"
@@ -30,6 +33,11 @@ func OutputRes(c *gin.Context, httpCode, errCode int, data interface{}) {
 	return
 }
 
+// SetRetryAfter set the Retry-After header to d, rounded up to whole seconds
+func SetRetryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
 func BindAndValid(c *gin.Context, form interface{}) (int, int) {
 	err := c.Bind(form)
 	if err != nil {
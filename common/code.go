@@ -4,6 +4,7 @@ const (
 	SUCCESS        = 200
 	ERROR          = 500
 	INVALID_PARAMS = 400
+	FORBIDDEN      = 403
 
 	ERROR_EXIST_TAG       = 10001
 	ERROR_EXIST_TAG_FAIL  = 10002
@@ -25,13 +26,62 @@ const (
 	ERROR_GET_ARTICLES_FAIL        = 10017
 	ERROR_GET_ARTICLE_FAIL         = 10018
 	ERROR_GEN_ARTICLE_POSTER_FAIL  = 10019
+	ERROR_SEARCH_ARTICLES_FAIL     = 10020
+
+	ERROR_GET_ARTICLE_REVISIONS_FAIL    = 10021
+	ERROR_NOT_EXIST_ARTICLE_REVISION    = 10022
+	ERROR_GET_ARTICLE_REVISION_FAIL     = 10023
+	ERROR_RESTORE_ARTICLE_REVISION_FAIL = 10024
+
+	ERROR_ARTICLE_TRANSITION           = 10025
+	ERROR_ARTICLE_PUBLISH_AT           = 10026
+	ERROR_TRANSITION_ARTICLE_FAIL      = 10027
+	ERROR_GET_ARTICLE_TRANSITIONS_FAIL = 10028
 
 	ERROR_AUTH_CHECK_TOKEN_FAIL    = 20001
 	ERROR_AUTH_CHECK_TOKEN_TIMEOUT = 20002
 	ERROR_AUTH_TOKEN               = 20003
 	ERROR_AUTH                     = 20004
+	ERROR_AUTH_REFRESH_TOKEN       = 20005
+	ERROR_AUTH_REFRESH_TOKEN_REUSE = 20006
+	ERROR_AUTH_TOKEN_REVOKED       = 20007
+	ERROR_AUTH_LOGOUT_FAIL         = 20008
+	ERROR_AUTH_API_KEY             = 20009
+	ERROR_ADD_API_KEY_FAIL         = 20010
+	ERROR_GET_API_KEYS_FAIL        = 20011
+	ERROR_NOT_EXIST_API_KEY        = 20012
+	ERROR_DELETE_API_KEY_FAIL      = 20013
+	ERROR_AUTH_TOO_MANY_ATTEMPTS   = 20014
+	ERROR_AUTH_MFA_REQUIRED        = 20015
+	ERROR_AUTH_MFA_TOKEN           = 20016
+	ERROR_AUTH_MFA_CODE            = 20017
+	ERROR_TOTP_ENROLL_FAIL         = 20018
+	ERROR_TOTP_ALREADY_ENABLED     = 20019
+	ERROR_TOTP_NOT_ENROLLED        = 20020
+	ERROR_FORGOT_PASSWORD_FAIL     = 20021
+	ERROR_RESET_TOKEN              = 20022
+	ERROR_RESET_PASSWORD_FAIL      = 20023
+	ERROR_OIDC_PROVIDER            = 20024
+	ERROR_OIDC_STATE               = 20025
+	ERROR_OIDC_LOGIN_FAIL          = 20026
+	ERROR_GET_SESSIONS_FAIL        = 20027
+	ERROR_NOT_EXIST_SESSION        = 20028
+	ERROR_DELETE_SESSION_FAIL      = 20029
+	ERROR_AUTH_SIGNATURE           = 20030
 
 	ERROR_UPLOAD_SAVE_IMAGE_FAIL    = 30001
 	ERROR_UPLOAD_CHECK_IMAGE_FAIL   = 30002
 	ERROR_UPLOAD_CHECK_IMAGE_FORMAT = 30003
+
+	ERROR_EXIST_USER               = 40001
+	ERROR_NOT_EXIST_USER           = 40002
+	ERROR_CHECK_EXIST_USER_FAIL    = 40003
+	ERROR_GET_USERS_FAIL           = 40004
+	ERROR_COUNT_USER_FAIL          = 40005
+	ERROR_GET_USER_FAIL            = 40006
+	ERROR_ADD_USER_FAIL            = 40007
+	ERROR_EDIT_USER_FAIL           = 40008
+	ERROR_DELETE_USER_FAIL         = 40009
+	ERROR_RESET_USER_PASSWORD_FAIL = 40010
+	ERROR_REVOKE_USER_TOKENS_FAIL  = 40011
 )
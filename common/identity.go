@@ -0,0 +1,63 @@
+package common
+
+import "github.com/gin-gonic/gin"
+
+const identityKey = "identity"
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	ID        int
+	Username  string
+	Role      string
+	TokenID   string
+	SessionID string
+	IssuedAt  int64
+	ExpiresAt int64
+
+	// ApiKeyID is set when the caller authenticated with an API key, which
+	// then limits the caller to Scopes
+	ApiKeyID int
+	Scopes   []string
+
+	// ServiceKeyID is set for HMAC signed requests, which aren't made on
+	// behalf of a user. Scopes limit them when configured.
+	ServiceKeyID string
+}
+
+// IsUser report whether the caller is a user signed in with a JWT rather
+// than an API key or a service
+func (i *Identity) IsUser() bool {
+	return i.ApiKeyID == 0 && i.ServiceKeyID == ""
+}
+
+// HasScope report whether the caller may use permission. Callers with a JWT,
+// and service keys without scopes, are only limited by their role.
+func (i *Identity) HasScope(permission string) bool {
+	if i.ApiKeyID == 0 && len(i.Scopes) == 0 {
+		return true
+	}
+
+	for _, scope := range i.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetIdentity store the authenticated caller in the gin context
+func SetIdentity(c *gin.Context, identity *Identity) {
+	c.Set(identityKey, identity)
+}
+
+// GetIdentity return the authenticated caller, or nil when the request was not authenticated
+func GetIdentity(c *gin.Context) *Identity {
+	v, ok := c.Get(identityKey)
+	if !ok {
+		return nil
+	}
+
+	identity, _ := v.(*Identity)
+	return identity
+}
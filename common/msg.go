@@ -1,35 +1,82 @@
 package common
 
 var MsgFlags = map[int]string{
-	SUCCESS:                         "ok",
-	ERROR:                           "fail",
-	INVALID_PARAMS:                  "请求参数错误",
-	ERROR_EXIST_TAG:                 "已存在该标签名称",
-	ERROR_EXIST_TAG_FAIL:            "获取已存在标签失败",
-	ERROR_NOT_EXIST_TAG:             "该标签不存在",
-	ERROR_GET_TAGS_FAIL:             "获取所有标签失败",
-	ERROR_COUNT_TAG_FAIL:            "统计标签失败",
-	ERROR_ADD_TAG_FAIL:              "新增标签失败",
-	ERROR_EDIT_TAG_FAIL:             "修改标签失败",
-	ERROR_DELETE_TAG_FAIL:           "删除标签失败",
-	ERROR_EXPORT_TAG_FAIL:           "导出标签失败",
-	ERROR_IMPORT_TAG_FAIL:           "导入标签失败",
-	ERROR_NOT_EXIST_ARTICLE:         "该文章不存在",
-	ERROR_ADD_ARTICLE_FAIL:          "新增文章失败",
-	ERROR_DELETE_ARTICLE_FAIL:       "删除文章失败",
-	ERROR_CHECK_EXIST_ARTICLE_FAIL:  "检查文章是否存在失败",
-	ERROR_EDIT_ARTICLE_FAIL:         "修改文章失败",
-	ERROR_COUNT_ARTICLE_FAIL:        "统计文章失败",
-	ERROR_GET_ARTICLES_FAIL:         "获取多个文章失败",
-	ERROR_GET_ARTICLE_FAIL:          "获取单个文章失败",
-	ERROR_GEN_ARTICLE_POSTER_FAIL:   "生成文章海报失败",
-	ERROR_AUTH_CHECK_TOKEN_FAIL:     "Token鉴权失败",
-	ERROR_AUTH_CHECK_TOKEN_TIMEOUT:  "Token已超时",
-	ERROR_AUTH_TOKEN:                "Token生成失败",
-	ERROR_AUTH:                      "Token错误",
-	ERROR_UPLOAD_SAVE_IMAGE_FAIL:    "保存图片失败",
-	ERROR_UPLOAD_CHECK_IMAGE_FAIL:   "检查图片失败",
-	ERROR_UPLOAD_CHECK_IMAGE_FORMAT: "校验图片错误，图片格式或大小有问题",
+	SUCCESS:                             "ok",
+	ERROR:                               "fail",
+	INVALID_PARAMS:                      "请求参数错误",
+	FORBIDDEN:                           "没有操作权限",
+	ERROR_EXIST_TAG:                     "已存在该标签名称",
+	ERROR_EXIST_TAG_FAIL:                "获取已存在标签失败",
+	ERROR_NOT_EXIST_TAG:                 "该标签不存在",
+	ERROR_GET_TAGS_FAIL:                 "获取所有标签失败",
+	ERROR_COUNT_TAG_FAIL:                "统计标签失败",
+	ERROR_ADD_TAG_FAIL:                  "新增标签失败",
+	ERROR_EDIT_TAG_FAIL:                 "修改标签失败",
+	ERROR_DELETE_TAG_FAIL:               "删除标签失败",
+	ERROR_EXPORT_TAG_FAIL:               "导出标签失败",
+	ERROR_IMPORT_TAG_FAIL:               "导入标签失败",
+	ERROR_NOT_EXIST_ARTICLE:             "该文章不存在",
+	ERROR_ADD_ARTICLE_FAIL:              "新增文章失败",
+	ERROR_DELETE_ARTICLE_FAIL:           "删除文章失败",
+	ERROR_CHECK_EXIST_ARTICLE_FAIL:      "检查文章是否存在失败",
+	ERROR_EDIT_ARTICLE_FAIL:             "修改文章失败",
+	ERROR_COUNT_ARTICLE_FAIL:            "统计文章失败",
+	ERROR_GET_ARTICLES_FAIL:             "获取多个文章失败",
+	ERROR_GET_ARTICLE_FAIL:              "获取单个文章失败",
+	ERROR_GEN_ARTICLE_POSTER_FAIL:       "生成文章海报失败",
+	ERROR_SEARCH_ARTICLES_FAIL:          "搜索文章失败",
+	ERROR_GET_ARTICLE_REVISIONS_FAIL:    "获取文章修订历史失败",
+	ERROR_NOT_EXIST_ARTICLE_REVISION:    "该文章修订版本不存在",
+	ERROR_GET_ARTICLE_REVISION_FAIL:     "获取文章修订版本失败",
+	ERROR_RESTORE_ARTICLE_REVISION_FAIL: "恢复文章修订版本失败",
+	ERROR_ARTICLE_TRANSITION:            "文章不能变更为该状态",
+	ERROR_ARTICLE_PUBLISH_AT:            "定时发布时间必须晚于当前时间",
+	ERROR_TRANSITION_ARTICLE_FAIL:       "变更文章状态失败",
+	ERROR_GET_ARTICLE_TRANSITIONS_FAIL:  "获取文章状态记录失败",
+	ERROR_AUTH_CHECK_TOKEN_FAIL:         "Token鉴权失败",
+	ERROR_AUTH_CHECK_TOKEN_TIMEOUT:      "Token已超时",
+	ERROR_AUTH_TOKEN:                    "Token生成失败",
+	ERROR_AUTH:                          "Token错误",
+	ERROR_AUTH_REFRESH_TOKEN:            "Refresh Token无效或已过期",
+	ERROR_AUTH_REFRESH_TOKEN_REUSE:      "Refresh Token已被使用，该登录已失效",
+	ERROR_AUTH_TOKEN_REVOKED:            "Token已被注销",
+	ERROR_AUTH_LOGOUT_FAIL:              "退出登录失败",
+	ERROR_AUTH_API_KEY:                  "API Key无效、已注销或已过期",
+	ERROR_ADD_API_KEY_FAIL:              "创建API Key失败",
+	ERROR_GET_API_KEYS_FAIL:             "获取API Key失败",
+	ERROR_NOT_EXIST_API_KEY:             "该API Key不存在",
+	ERROR_DELETE_API_KEY_FAIL:           "注销API Key失败",
+	ERROR_AUTH_TOO_MANY_ATTEMPTS:        "登录失败次数过多，请稍后再试",
+	ERROR_AUTH_MFA_REQUIRED:             "需要输入二次验证码",
+	ERROR_AUTH_MFA_TOKEN:                "二次验证已超时，请重新登录",
+	ERROR_AUTH_MFA_CODE:                 "二次验证码错误",
+	ERROR_TOTP_ENROLL_FAIL:              "设置二次验证失败",
+	ERROR_TOTP_ALREADY_ENABLED:          "已开启二次验证",
+	ERROR_TOTP_NOT_ENROLLED:             "未开启二次验证",
+	ERROR_FORGOT_PASSWORD_FAIL:          "发送重置密码邮件失败",
+	ERROR_RESET_TOKEN:                   "重置密码链接无效或已过期",
+	ERROR_RESET_PASSWORD_FAIL:           "重置密码失败",
+	ERROR_OIDC_PROVIDER:                 "身份提供方不存在",
+	ERROR_OIDC_STATE:                    "登录状态无效或已过期",
+	ERROR_OIDC_LOGIN_FAIL:               "身份提供方登录失败",
+	ERROR_GET_SESSIONS_FAIL:             "获取登录设备失败",
+	ERROR_NOT_EXIST_SESSION:             "该登录设备不存在",
+	ERROR_DELETE_SESSION_FAIL:           "退出登录设备失败",
+	ERROR_AUTH_SIGNATURE:                "请求签名无效、已过期或已被使用",
+	ERROR_UPLOAD_SAVE_IMAGE_FAIL:        "保存图片失败",
+	ERROR_UPLOAD_CHECK_IMAGE_FAIL:       "检查图片失败",
+	ERROR_UPLOAD_CHECK_IMAGE_FORMAT:     "校验图片错误，图片格式或大小有问题",
+	ERROR_EXIST_USER:                    "已存在该用户名",
+	ERROR_NOT_EXIST_USER:                "该用户不存在",
+	ERROR_CHECK_EXIST_USER_FAIL:         "检查用户是否存在失败",
+	ERROR_GET_USERS_FAIL:                "获取多个用户失败",
+	ERROR_COUNT_USER_FAIL:               "统计用户失败",
+	ERROR_GET_USER_FAIL:                 "获取单个用户失败",
+	ERROR_ADD_USER_FAIL:                 "新增用户失败",
+	ERROR_EDIT_USER_FAIL:                "修改用户失败",
+	ERROR_DELETE_USER_FAIL:              "删除用户失败",
+	ERROR_RESET_USER_PASSWORD_FAIL:      "重置用户密码失败",
+	ERROR_REVOKE_USER_TOKENS_FAIL:       "注销用户Token失败",
 }
 
 // GetMsg get error information based on Code
@@ -3,4 +3,11 @@ package common
 const (
 	CACHE_ARTICLE = "ARTICLE"
 	CACHE_TAG     = "TAG"
+
+	CACHE_TOKEN_DENYLIST      = "TOKEN_DENYLIST"
+	CACHE_USER_TOKEN_DENYLIST = "USER_TOKEN_DENYLIST"
+	CACHE_LOGIN_ATTEMPTS      = "LOGIN_ATTEMPTS"
+	CACHE_LOGIN_BLOCKED       = "LOGIN_BLOCKED"
+	CACHE_REQUEST_NONCE       = "REQUEST_NONCE"
+	CACHE_MARKDOWN            = "MARKDOWN"
 )
@@ -0,0 +1,134 @@
+package gredis
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+var RedisConn *redis.Pool
+
+// Setup initialize the Redis connection pool, connections are only dialed when used
+func Setup() {
+	RedisConn = &redis.Pool{
+		MaxIdle:     settings.RedisSetting.MaxIdle,
+		MaxActive:   settings.RedisSetting.MaxActive,
+		IdleTimeout: settings.RedisSetting.IdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", settings.RedisSetting.Host)
+			if err != nil {
+				return nil, err
+			}
+			if settings.RedisSetting.Password != "" {
+				if _, err := c.Do("AUTH", settings.RedisSetting.Password); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+			return c, err
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// Set store value under key for ttl
+func Set(key, value string, ttl time.Duration) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	seconds := int64(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	_, err := conn.Do("SET", key, value, "EX", seconds)
+	return err
+}
+
+// SetNX store value under key for ttl unless the key is already set, ok is
+// false when it was
+func SetNX(key, value string, ttl time.Duration) (ok bool, err error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err = redis.String(conn.Do("SET", key, value, "PX", int64(ttl/time.Millisecond), "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Exists check whether key is set
+func Exists(key string) (bool, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("EXISTS", key))
+}
+
+// Get return the value stored under key, ok is false when the key is not set
+func Get(key string) (value string, ok bool, err error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	value, err = redis.String(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+// Delete remove key
+func Delete(key string) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", key)
+	return err
+}
+
+// Incr increment the counter under key and (re)set its ttl
+func Incr(key string, ttl time.Duration) (int, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("INCR", key))
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := conn.Do("PEXPIRE", key, int64(ttl/time.Millisecond)); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// TTL return the remaining time to live of key, 0 when it is not set
+func TTL(key string) (time.Duration, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	ms, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+	if ms < 0 {
+		return 0, nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
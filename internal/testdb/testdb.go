@@ -0,0 +1,32 @@
+// Package testdb open the in-memory SQLite databases the tests run the
+// models on.
+package testdb
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// Open open an empty in-memory database, hand it to use, e.g. models.UseDB,
+// and create the tables of values. The database is closed with the test.
+func Open(t *testing.T, use func(*gorm.DB), values ...interface{}) *gorm.DB {
+	t.Helper()
+
+	conn, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	use(conn)
+	// every connection to :memory: is a database of its own, so once use
+	// has configured the pool there must be only one
+	conn.DB().SetMaxOpenConns(1)
+	conn.LogMode(false)
+	if err := conn.AutoMigrate(values...).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer deliver plain text mails
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// SMTPMailer send through an SMTP server, upgrading to TLS when it offers STARTTLS
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.Addr, auth, from.Address, []string{msg.To}, format(m.From, msg))
+}
+
+// FileMailer append every mail to a file. It lets tests and offline
+// environments read the mails that would have been sent. With no file only
+// the recipient and subject are logged, the body may hold a live token.
+type FileMailer struct {
+	Path string
+	From string
+
+	mu sync.Mutex
+}
+
+func (m *FileMailer) Send(msg *Message) error {
+	if m.Path == "" {
+		logging.Warn("mailer.FileMailer no FilePath, mail not kept", msg.To, msg.Subject)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\n", format(m.From, msg))
+	return err
+}
+
+func format(from string, msg *Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+var mailer Mailer = &FileMailer{}
+
+// Setup select the mailer configured in the [mail] section
+func Setup() {
+	switch settings.MailSetting.Type {
+	case "smtp":
+		mailer = &SMTPMailer{
+			Addr:     settings.MailSetting.Host,
+			Username: settings.MailSetting.User,
+			Password: settings.MailSetting.Password,
+			From:     settings.MailSetting.From,
+		}
+	case "", "file":
+		mailer = &FileMailer{Path: settings.MailSetting.FilePath, From: settings.MailSetting.From}
+	default:
+		log.Fatalf("mailer.Setup, unknown mail type %q", settings.MailSetting.Type)
+	}
+}
+
+// Send deliver msg with the configured mailer
+func Send(msg *Message) error {
+	return mailer.Send(msg)
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	msg := &Message{To: "alice@example.com", Subject: "Reset your password", Body: "token: secret-reset-token"}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantFile bool
+	}{
+		{name: "file", path: filepath.Join(dir, "mail.log"), wantFile: true},
+		{name: "no file", path: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// nothing of the mail reaches stdout
+			stdout := os.Stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Stdout = w
+			err = (&FileMailer{Path: tt.path, From: "blog <no-reply@example.com>"}).Send(msg)
+			os.Stdout = stdout
+			w.Close()
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if out, _ := ioutil.ReadAll(r); len(out) > 0 {
+				t.Errorf("Send() wrote %q to stdout", out)
+			}
+
+			if !tt.wantFile {
+				return
+			}
+			content, err := ioutil.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), "To: alice@example.com\r\n") || !strings.Contains(string(content), msg.Body) {
+				t.Errorf("Send() wrote %q, want the whole mail", content)
+			}
+		})
+	}
+}
@@ -9,21 +9,49 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/miaozhang/webservice/gredis"
 	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/mailer"
+	"github.com/miaozhang/webservice/markdown"
 	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/oidc"
 	"github.com/miaozhang/webservice/routers"
+	"github.com/miaozhang/webservice/search"
+	"github.com/miaozhang/webservice/service/article_service"
+	"github.com/miaozhang/webservice/service/auth_service"
 	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
 )
 
 func init() {
 	settings.Setup()
 	models.Setup()
 	logging.Setup()
+	gredis.Setup()
+	mailer.Setup()
+	markdown.Setup()
+	oidc.Setup()
+	search.Setup()
+	util.Setup()
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	router := routers.InitRouter()
 
+	stopScheduler := make(chan struct{})
+	if settings.AppSetting.PublishInterval > 0 {
+		go article_service.RunScheduler(settings.AppSetting.PublishInterval, stopScheduler)
+	}
+	if settings.AppSetting.TokenDenylist != "redis" {
+		go auth_service.RunRevocationSync(auth_service.RevocationSyncInterval, stopScheduler)
+	}
+	go auth_service.RunSessionTouchPrune(stopScheduler)
+
 	s := &http.Server{
 		Addr:           fmt.Sprintf("0.0.0.0:%d", settings.ServerSetting.HttpPort),
 		Handler:        router,
@@ -39,11 +67,12 @@ func main() {
 		}
 	}()
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
 	log.Println("Shutdown Server ...")
+	close(stopScheduler)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
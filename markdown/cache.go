@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/gredis"
+)
+
+const (
+	// memoryCacheSize is the number of rendered documents the memory cache hold
+	memoryCacheSize = 1000
+	// redisCacheTTL let Redis forget the HTML of content that isn't read anymore
+	redisCacheTTL = 7 * 24 * time.Hour
+)
+
+// Cache hold rendered HTML by the hash of its source
+type Cache interface {
+	Get(key string) (string, bool, error)
+	Set(key, html string) error
+}
+
+type cacheEntry struct {
+	key  string
+	html string
+}
+
+// MemoryCache keep the least recently used documents in the memory of one process
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	m.order.MoveToFront(e)
+
+	return e.Value.(*cacheEntry).html, true, nil
+}
+
+func (m *MemoryCache) Set(key, html string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.Value.(*cacheEntry).html = html
+		m.order.MoveToFront(e)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&cacheEntry{key: key, html: html})
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	return nil
+}
+
+// RedisCache share the rendered documents between every instance through Redis
+type RedisCache struct{}
+
+func (r *RedisCache) Get(key string) (string, bool, error) {
+	return gredis.Get(redisKey(key))
+}
+
+func (r *RedisCache) Set(key, html string) error {
+	return gredis.Set(redisKey(key), html, redisCacheTTL)
+}
+
+func redisKey(key string) string {
+	return strings.Join([]string{common.CACHE_MARKDOWN, key}, "_")
+}
@@ -0,0 +1,84 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// renderVersion is part of the cache keys, bump it whenever the renderer or
+// the sanitizer policy change so stale HTML isn't served
+const renderVersion = "1"
+
+// renderer convert CommonMark with the GFM tables, strikethrough, autolinks
+// and task lists. Fenced code blocks get a language-<lang> class for the
+// client side highlighters. Raw HTML is kept, the sanitizer decides.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// policy is the allow-list of the user generated content policy, plus the
+// classes of code blocks and the checkboxes of task lists
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+var cache Cache = NewMemoryCache(memoryCacheSize)
+
+// Setup select the cache of rendered HTML configured by MarkdownCache
+func Setup() {
+	switch settings.AppSetting.MarkdownCache {
+	case "redis":
+		cache = &RedisCache{}
+	case "", "memory":
+		cache = NewMemoryCache(memoryCacheSize)
+	default:
+		log.Fatalf("markdown.Setup, unknown markdown cache %q", settings.AppSetting.MarkdownCache)
+	}
+}
+
+// Render return the sanitized HTML of the markdown source. The result is
+// cached under the hash of the source, a failing cache is only logged.
+func Render(source string) (string, error) {
+	sum := sha256.Sum256([]byte(source))
+	key := renderVersion + ":" + hex.EncodeToString(sum[:])
+
+	rendered, ok, err := cache.Get(key)
+	if err != nil {
+		logging.Warn("markdown.Render get from cache fail", err)
+	}
+	if ok {
+		return rendered, nil
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	rendered = policy.Sanitize(buf.String())
+
+	if err := cache.Set(key, rendered); err != nil {
+		logging.Warn("markdown.Render set cache fail", err)
+	}
+	return rendered, nil
+}
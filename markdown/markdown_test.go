@@ -0,0 +1,151 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	cache = NewMemoryCache(memoryCacheSize)
+
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "script",
+			source:  "hello <script>alert(1)</script>",
+			want:    []string{"hello"},
+			notWant: []string{"<script", "alert(1)"},
+		},
+		{
+			name:    "javascript link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "raw javascript link",
+			source:  `<a href="javascript:alert(1)">click</a>`,
+			want:    []string{"click"},
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "event handler attributes",
+			source:  `<img src="/a.png" onerror="alert(1)"> <p onclick="alert(1)">text</p>`,
+			want:    []string{`<img src="/a.png"`, "text"},
+			notWant: []string{"onerror", "onclick"},
+		},
+		{
+			name:   "GFM table",
+			source: "| a | b |\n|:--|--:|\n| 1 | 2 |\n",
+			want:   []string{"<table>", "<th align=\"left\">a</th>", "<td align=\"right\">2</td>"},
+		},
+		{
+			name:    "fenced code keeps its language class",
+			source:  "```go\nfmt.Println(1)\n```\n",
+			want:    []string{`<code class="language-go">`},
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "other classes are stripped",
+			source:  `<code class="evil">x</code>`,
+			want:    []string{"<code>x</code>"},
+			notWant: []string{"evil"},
+		},
+		{
+			name:   "task list",
+			source: "- [x] done\n",
+			want:   []string{`<input checked="" disabled="" type="checkbox"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("Render() = %q, want it to contain %q", html, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(html, notWant) {
+					t.Errorf("Render() = %q, want no %q", html, notWant)
+				}
+			}
+		})
+	}
+}
+
+// countingCache count the lookups of a MemoryCache that hit
+type countingCache struct {
+	*MemoryCache
+	hits, sets int
+}
+
+func (c *countingCache) Get(key string) (string, bool, error) {
+	html, ok, err := c.MemoryCache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return html, ok, err
+}
+
+func (c *countingCache) Set(key, html string) error {
+	c.sets++
+	return c.MemoryCache.Set(key, html)
+}
+
+func TestRenderCache(t *testing.T) {
+	counting := &countingCache{MemoryCache: NewMemoryCache(memoryCacheSize)}
+	cache = counting
+
+	first, err := Render("# title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Render("# title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Render() from the cache = %q, want %q", second, first)
+	}
+	if counting.hits != 1 || counting.sets != 1 {
+		t.Errorf("Render() twice hit the cache %d times and rendered %d times, want 1 and 1", counting.hits, counting.sets)
+	}
+
+	if _, err := Render("# other title"); err != nil {
+		t.Fatal(err)
+	}
+	if counting.sets != 2 {
+		t.Errorf("Render() of another source rendered %d times in total, want 2", counting.sets)
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", "A")
+	c.Set("b", "B")
+	c.Get("a")
+	c.Set("c", "C")
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+	for _, tt := range tests {
+		if _, ok, _ := c.Get(tt.key); ok != tt.want {
+			t.Errorf("Get(%q) found = %v, want %v", tt.key, ok, tt.want)
+		}
+	}
+}
@@ -1,36 +1,58 @@
 package jwt
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/service/api_key_service"
+	"github.com/miaozhang/webservice/service/auth_service"
+	"github.com/miaozhang/webservice/settings"
 	"github.com/miaozhang/webservice/util"
 )
 
+// maxSignedBodySize bound the body read to check the digest of a signed request
+const maxSignedBodySize = 32 << 20
+
+// JWT authenticate the request with a JWT, an API key or an HMAC signature
 func JWT() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var code int
 		var data interface{}
+		var identity *common.Identity
 
-		println("hello world")
 		code = common.SUCCESS
-		token := c.Query("token")
-		if token == "" {
+		token, malformed := getToken(c)
+		if credentials, ok := signatureCredentials(c); ok {
+			identity, code = checkSignature(c, credentials)
+		} else if token == "" {
 			code = common.INVALID_PARAMS
-		} else {
-			claims, err := util.ParseToken(token)
-			if err != nil {
+		} else if api_key_service.IsApiKey(token) {
+			var err error
+			identity, err = api_key_service.Authenticate(token)
+			if err == api_key_service.ErrApiKeyInvalid {
+				code = common.ERROR_AUTH_API_KEY
+			} else if err != nil {
 				code = common.ERROR_AUTH_CHECK_TOKEN_FAIL
-			} else if time.Now().Unix() > claims.ExpiresAt {
-				code = common.ERROR_AUTH_CHECK_TOKEN_TIMEOUT
 			}
+		} else {
+			identity, code = checkToken(token)
 		}
 
 		if code != common.SUCCESS {
-			c.JSON(http.StatusUnauthorized, gin.H{
+			httpCode := http.StatusUnauthorized
+			if malformed {
+				httpCode = http.StatusBadRequest
+			}
+			c.Header("WWW-Authenticate", authenticateChallenge(code, malformed))
+			c.JSON(httpCode, gin.H{
 				"code": code,
 				"msg":  common.GetMsg(code),
 				"data": data,
@@ -40,6 +62,141 @@ func JWT() gin.HandlerFunc {
 			return
 		}
 
+		common.SetIdentity(c, identity)
+		if identity.SessionID != "" {
+			if err := auth_service.TouchSession(identity.SessionID, c.ClientIP()); err != nil {
+				logging.Warn("jwt.JWT touch session fail", identity.SessionID, err)
+			}
+		}
+
 		c.Next()
 	}
 }
+
+// checkToken parse and validate a JWT, returning the caller it identifies
+func checkToken(token string) (*common.Identity, int) {
+	claims, err := util.ParseToken(token)
+	if util.IsTokenExpired(err) {
+		return nil, common.ERROR_AUTH_CHECK_TOKEN_TIMEOUT
+	} else if err != nil {
+		return nil, common.ERROR_AUTH_CHECK_TOKEN_FAIL
+	}
+
+	revoked, err := util.IsTokenRevoked(claims)
+	if err != nil {
+		return nil, common.ERROR_AUTH_CHECK_TOKEN_FAIL
+	} else if revoked {
+		return nil, common.ERROR_AUTH_TOKEN_REVOKED
+	}
+
+	return &common.Identity{
+		ID:        claims.UserID(),
+		Username:  claims.Username,
+		Role:      claims.Role,
+		TokenID:   claims.Id,
+		SessionID: claims.SessionID,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}, common.SUCCESS
+}
+
+// signatureCredentials return what follows the scheme of an HMAC signed
+// request's Authorization header
+func signatureCredentials(c *gin.Context) (string, bool) {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != util.SignatureScheme {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+// checkSignature verify an HMAC signed request, the body is read for its
+// digest and put back for the handler
+func checkSignature(c *gin.Context, credentials string) (*common.Identity, int) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+		if err != nil || len(body) > maxSignedBodySize {
+			return nil, common.ERROR_AUTH_SIGNATURE
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	identity, err := auth_service.AuthenticateSignature(credentials, c.Request.Method, c.Request.URL.RequestURI(), body)
+	switch err {
+	case nil:
+		return identity, common.SUCCESS
+	case auth_service.ErrSignatureInvalid, auth_service.ErrSignatureExpired, auth_service.ErrSignatureReplayed:
+		logging.Warn("jwt.JWT signed request rejected", c.ClientIP(), err)
+		return nil, common.ERROR_AUTH_SIGNATURE
+	default:
+		return nil, common.ERROR_AUTH_CHECK_TOKEN_FAIL
+	}
+}
+
+// getToken read the access token or API key from the Authorization header,
+// then the X-API-Key header, then the configured cookie, then the legacy query
+// parameter if it is still enabled. API keys are only taken from headers.
+// malformed is true when an Authorization header uses the Bearer scheme
+// without a token.
+func getToken(c *gin.Context) (token string, malformed bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		parts := strings.SplitN(header, " ", 2)
+		if strings.EqualFold(parts[0], "Bearer") {
+			if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+				return "", true
+			}
+			return strings.TrimSpace(parts[1]), false
+		}
+	}
+
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, false
+	}
+
+	if name := settings.AppSetting.JwtCookieName; name != "" {
+		if cookie, err := c.Cookie(name); err == nil && cookie != "" && !api_key_service.IsApiKey(cookie) {
+			return cookie, false
+		}
+	}
+
+	if settings.AppSetting.JwtQueryToken {
+		if token := c.Query("token"); !api_key_service.IsApiKey(token) {
+			return token, false
+		}
+	}
+
+	return "", false
+}
+
+// authenticateChallenge build the WWW-Authenticate value described in RFC 6750 section 3
+func authenticateChallenge(code int, malformed bool) string {
+	challenge := fmt.Sprintf(`Bearer realm="%s"`, settings.AppSetting.JwtIssuer)
+
+	switch {
+	case malformed:
+		return challenge + `, error="invalid_request"`
+	case code == common.INVALID_PARAMS:
+		// no credentials at all, RFC 6750 asks for a bare challenge
+		return challenge
+	default:
+		return fmt.Sprintf(`%s, error="invalid_token", error_description="%s"`, challenge, tokenErrorDescription(code))
+	}
+}
+
+func tokenErrorDescription(code int) string {
+	switch code {
+	case common.ERROR_AUTH_CHECK_TOKEN_TIMEOUT:
+		return "The access token expired"
+	case common.ERROR_AUTH_TOKEN_REVOKED:
+		return "The access token was revoked"
+	case common.ERROR_AUTH_API_KEY:
+		return "The API key is invalid, revoked or expired"
+	case common.ERROR_AUTH_SIGNATURE:
+		return "The request signature is invalid, expired or replayed"
+	default:
+		return "The access token is invalid"
+	}
+}
@@ -0,0 +1,40 @@
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+// RequirePermission only let the request through when the role in the
+// caller's token grants permission and, for API keys, the key is scoped to
+// it. It must run after jwt.JWT.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := common.SUCCESS
+		httpCode := http.StatusOK
+
+		identity := common.GetIdentity(c)
+		if identity == nil {
+			code = common.ERROR_AUTH_CHECK_TOKEN_FAIL
+			httpCode = http.StatusUnauthorized
+		} else if ok, err := auth_service.HasPermission(identity.Role, permission); err != nil {
+			code = common.ERROR
+			httpCode = http.StatusInternalServerError
+		} else if !ok || !identity.HasScope(permission) {
+			code = common.FORBIDDEN
+			httpCode = http.StatusForbidden
+		}
+
+		if code != common.SUCCESS {
+			common.OutputRes(c, httpCode, code, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
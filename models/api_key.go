@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type ApiKey struct {
+	Model
+
+	AuthID     int    `json:"auth_id" gorm:"index"`
+	Name       string `json:"name" gorm:"size:100"`
+	Prefix     string `json:"prefix" gorm:"size:16;index"`
+	KeyHash    string `json:"-" gorm:"size:64;unique_index"`
+	Scopes     string `json:"scopes" gorm:"size:500"`
+	ExpiresOn  int    `json:"expires_on"`
+	LastUsedOn int    `json:"last_used_on"`
+	RevokedOn  int    `json:"revoked_on"`
+}
+
+func AddApiKey(authID int, name, prefix, keyHash, scopes string, expiresOn int) error {
+	key := &ApiKey{
+		AuthID:    authID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    scopes,
+		ExpiresOn: expiresOn,
+	}
+
+	if err := db.Create(key).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetApiKeyByHash(keyHash string) (*ApiKey, error) {
+	var key ApiKey
+	err := db.Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func GetApiKeys(authID int) ([]*ApiKey, error) {
+	var keys []*ApiKey
+	err := db.Where("auth_id = ? AND revoked_on = ?", authID, 0).Order("id desc").Find(&keys).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func ExistApiKey(id, authID int) (bool, error) {
+	var key ApiKey
+	err := db.Select("id").Where("id = ? AND auth_id = ? AND revoked_on = ?", id, authID, 0).First(&key).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+
+	if key.ID > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func TouchApiKey(id int, lastUsedOn int) error {
+	err := db.Model(&ApiKey{}).Where("id = ?", id).UpdateColumn("last_used_on", lastUsedOn).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeApiKeysByAuth revoke every API key of the user
+func RevokeApiKeysByAuth(authID int) error {
+	err := db.Model(&ApiKey{}).Where("auth_id = ? AND revoked_on = ?", authID, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func RevokeApiKey(id int) error {
+	err := db.Model(&ApiKey{}).Where("id = ? AND revoked_on = ?", id, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
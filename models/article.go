@@ -7,15 +7,60 @@ import (
 type Article struct {
 	Model
 
-	TagID int `json:"tag_id" gorm:"index"`
-	Tag   Tag `json:"tag"`
+	// TagID and Tag are the first of Tags, kept for v1 clients
+	TagID int   `json:"tag_id" gorm:"index"`
+	Tag   Tag   `json:"tag"`
+	Tags  []Tag `json:"tags" gorm:"many2many:article_tag;association_autoupdate:false;association_autocreate:false"`
 
 	Title      string `json:"title"`
+	Slug       string `json:"slug" gorm:"size:100"`
 	Desc       string `json:"desc"`
 	Content    string `json:"content"`
 	CreatedBy  string `json:"created_by"`
 	ModifiedBy string `json:"modified_by"`
-	State      int    `json:"state"`
+	State      int    `json:"state" gorm:"index:idx_article_publish"`
+	// PublishAt is when a scheduled article goes live, or when it was published
+	PublishAt int `json:"publish_at" gorm:"index:idx_article_publish"`
+
+	// CreatedByID is the Auth.ID of the author, who may edit and delete the article
+	CreatedByID int `json:"created_by_id" gorm:"index"`
+
+	// CoverImageUrl is the public url of the cover, usually from /upload/image
+	CoverImageUrl string `json:"cover_image_url" gorm:"size:255"`
+
+	// ContentHTML is the sanitized rendering of the markdown Content, only
+	// filled for a single article
+	ContentHTML string `json:"content_html,omitempty" gorm:"-"`
+}
+
+// article states, draft and published keep the values of the former 0/1 flag
+const (
+	ARTICLE_STATE_DRAFT     = 0
+	ARTICLE_STATE_PUBLISHED = 1
+	ARTICLE_STATE_IN_REVIEW = 2
+	ARTICLE_STATE_SCHEDULED = 3
+	ARTICLE_STATE_ARCHIVED  = 4
+)
+
+// ArticleVisibility restrict articles to the published ones and those
+// written by AuthorID, nil restricts nothing
+type ArticleVisibility struct {
+	AuthorID int
+}
+
+// scope apply the restriction to a query on articles
+func (v *ArticleVisibility) scope(db *gorm.DB) *gorm.DB {
+	if v == nil {
+		return db
+	}
+
+	return db.Where("state = ? OR (created_by_id > 0 AND created_by_id = ?)", ARTICLE_STATE_PUBLISHED, v.AuthorID)
+}
+
+// Allows report whether the article is visible
+func (v *ArticleVisibility) Allows(article *Article) bool {
+	return v == nil || article.State == ARTICLE_STATE_PUBLISHED ||
+		(article.CreatedByID > 0 && article.CreatedByID == v.AuthorID)
 }
 
 func ExistArticleByID(id int) (bool, error) {
@@ -32,9 +77,9 @@ func ExistArticleByID(id int) (bool, error) {
 	return false, nil
 }
 
-func GetArticleTotal(maps interface{}) (int, error) {
+func GetArticleTotal(maps interface{}, tags *TagFilter, visible *ArticleVisibility) (int, error) {
 	var count int
-	if err := db.Model(&Article{}).Where(maps).Count(&count).Error; err != nil {
+	if err := db.Model(&Article{}).Scopes(tags.scope, visible.scope).Where(maps).Count(&count).Error; err != nil {
 		return 0, err
 	}
 
@@ -53,13 +98,29 @@ func GetArticle(id int) (*Article, error) {
 		return nil, err
 	}
 
+	err = db.Model(&article).Association("Tags").Find(&article.Tags).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
 	return &article, nil
 }
 
-func GetArticles(pageNum int, pageSize int, maps interface{}) ([]*Article, error) {
+// GetArticleIDBySlug return the id of the article having the slug, 0 when none
+func GetArticleIDBySlug(slug string) (int, error) {
+	var article Article
+	err := db.Select("id").Where("slug = ? AND deleted_on = ?", slug, 0).First(&article).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, err
+	}
+
+	return article.ID, nil
+}
+
+func GetArticles(pageNum int, pageSize int, maps interface{}, tags *TagFilter, visible *ArticleVisibility) ([]*Article, error) {
 	var articles []*Article
 
-	err := db.Preload("Tag").Where(maps).Offset(pageNum).Limit(pageSize).Find(&articles).Error
+	err := db.Preload("Tag").Preload("Tags").Scopes(tags.scope, visible.scope).Where(maps).Offset(pageNum).Limit(pageSize).Find(&articles).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
@@ -67,16 +128,95 @@ func GetArticles(pageNum int, pageSize int, maps interface{}) ([]*Article, error
 	return articles, nil
 }
 
-func EditArticle(id int, data interface{}) error {
-	err := db.Model(&Article{}).Where("id = ? AND deleted_on = ?", id, 0).Updates(data).Error
+// editOnlyKeys are the keys of EditArticle data that are not article columns
+var editOnlyKeys = map[string]bool{"tag_ids": true, "modified_by_id": true, "restored_from": true, "from_state": true}
+
+// EditArticle update the article and snapshot the result as a new revision.
+// Its tags are replaced when data has tag_ids, modified_by_id and
+// restored_from are only recorded on the revision. When data has a state
+// the transition from from_state is recorded along, and false is returned,
+// changing nothing, if the article is no longer in from_state.
+func EditArticle(id int, data map[string]interface{}) (bool, error) {
+	edited := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var article Article
+		err := forUpdate(tx).Where("id = ? AND deleted_on = ?", id, 0).First(&article).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		to, transition := data["state"].(int)
+		if transition && article.State != data["from_state"].(int) {
+			return nil
+		}
+		if err := addBaseArticleRevision(tx, &article); err != nil {
+			return err
+		}
+
+		updates := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			if !editOnlyKeys[k] {
+				updates[k] = v
+			}
+		}
+		if title, ok := data["title"].(string); ok {
+			slug, err := renameSlug(tx, SLUG_KIND_ARTICLE, id, article.Slug, article.Title, title)
+			if err != nil {
+				return err
+			}
+			updates["slug"] = slug
+		}
+
+		err = tx.Model(&Article{}).Where("id = ? AND deleted_on = ?", id, 0).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+
+		if tagIDs, ok := data["tag_ids"].([]int); ok {
+			if err := replaceArticleTags(tx, id, tagIDs); err != nil {
+				return err
+			}
+		}
+
+		authorID, _ := data["modified_by_id"].(int)
+		author, _ := data["modified_by"].(string)
+		if transition {
+			err := tx.Create(&ArticleTransition{
+				ArticleID: id,
+				FromState: article.State,
+				ToState:   to,
+				PublishAt: data["publish_at"].(int),
+				ActorID:   authorID,
+				Actor:     author,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var revision Article
+		if err := tx.Where("id = ?", id).First(&revision).Error; err != nil {
+			return err
+		}
+		restoredFrom, _ := data["restored_from"].(int)
+		if err := addArticleRevision(tx, &revision, authorID, author, restoredFrom); err != nil {
+			return err
+		}
+
+		edited = true
+		return nil
+	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return edited, nil
 }
 
-func AddArticle(data map[string]interface{}) error {
+// AddArticle create the article and return its id
+func AddArticle(data map[string]interface{}) (int, error) {
 	article := Article{
 		TagID:     data["tag_id"].(int),
 		Title:     data["title"].(string),
@@ -84,19 +224,65 @@ func AddArticle(data map[string]interface{}) error {
 		Content:   data["content"].(string),
 		CreatedBy: data["created_by"].(string),
 		State:     data["state"].(int),
+		PublishAt: data["publish_at"].(int),
+
+		CreatedByID: data["created_by_id"].(int),
 	}
+	article.CoverImageUrl, _ = data["cover_image_url"].(string)
 
-	if err := db.Create(&article).Error; err != nil {
-		return err
+	err := db.Transaction(func(tx *gorm.DB) error {
+		slug, err := allocateSlug(tx, SLUG_KIND_ARTICLE, article.Title, 0)
+		if err != nil {
+			return err
+		}
+		article.Slug = slug
+
+		if err := tx.Create(&article).Error; err != nil {
+			return err
+		}
+
+		tagIDs, _ := data["tag_ids"].([]int)
+		if len(tagIDs) == 0 {
+			tagIDs = []int{article.TagID}
+		}
+		if err := replaceArticleTags(tx, article.ID, tagIDs); err != nil {
+			return err
+		}
+
+		// an article created past draft went through that transition
+		if article.State != ARTICLE_STATE_DRAFT {
+			err := tx.Create(&ArticleTransition{
+				ArticleID: article.ID,
+				FromState: ARTICLE_STATE_DRAFT,
+				ToState:   article.State,
+				PublishAt: article.PublishAt,
+				ActorID:   article.CreatedByID,
+				Actor:     article.CreatedBy,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return addArticleRevision(tx, &article, article.CreatedByID, article.CreatedBy, 0)
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return article.ID, nil
 }
 
 func DeleteArticle(id int) error {
 	if err := db.Where("id = ?", id).Delete(Article{}).Error; err != nil {
 		return err
 	}
+	if err := db.Where("article_id = ?", id).Delete(ArticleTag{}).Error; err != nil {
+		return err
+	}
+	if err := DeleteSlugRedirects(SLUG_KIND_ARTICLE, id); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -108,3 +294,103 @@ func CleanAllArticle() error {
 
 	return nil
 }
+
+// backfillArticleAuthors set created_by_id of the articles written before it
+// existed by matching created_by against the usernames. The column is NULL
+// in the rows that were there when it was added.
+func backfillArticleAuthors() error {
+	articleTable := db.NewScope(&Article{}).TableName()
+	authTable := db.NewScope(&Auth{}).TableName()
+
+	author := "SELECT MIN(u.id) FROM " + authTable + " u WHERE u.username = " + articleTable + ".created_by"
+	return db.Exec("UPDATE " + articleTable + " SET created_by_id = (" + author + ")" +
+		" WHERE (created_by_id = 0 OR created_by_id IS NULL) AND EXISTS (" + author + ")").Error
+}
+
+// GetAllArticles return every article that isn't deleted, without its tag
+func GetAllArticles() ([]*Article, error) {
+	var articles []*Article
+	err := db.Where("deleted_on = ?", 0).Find(&articles).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+// GetArticlesByIDs return the visible articles with the ids, in no particular order
+func GetArticlesByIDs(ids []int, visible *ArticleVisibility) ([]*Article, error) {
+	var articles []*Article
+	if len(ids) == 0 {
+		return articles, nil
+	}
+
+	err := db.Preload("Tag").Preload("Tags").Scopes(visible.scope).Where("id IN (?) AND deleted_on = ?", ids, 0).Find(&articles).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+type ArticleScore struct {
+	ID    int
+	Score float64
+}
+
+const articleFulltextIndex = "ft_article"
+
+// GetVisibleArticleIDs return which of the ids are of visible articles
+func GetVisibleArticleIDs(ids []int, visible *ArticleVisibility) ([]int, error) {
+	visibleIDs := []int{}
+	if len(ids) == 0 {
+		return visibleIDs, nil
+	}
+
+	err := db.Model(&Article{}).Scopes(visible.scope).Where("id IN (?) AND deleted_on = ?", ids, 0).
+		Pluck("id", &visibleIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return visibleIDs, nil
+}
+
+// SearchArticles rank the visible articles against query with the FULLTEXT
+// index in natural language mode and return a page of ids with their relevance
+func SearchArticles(query string, visible *ArticleVisibility, offset, limit int) ([]*ArticleScore, int, error) {
+	match := "MATCH (`title`, `desc`, `content`) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+	var total int
+	err := db.Model(&Article{}).Scopes(visible.scope).Where("deleted_on = ? AND "+match, 0, query).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var scores []*ArticleScore
+	err = db.Model(&Article{}).Scopes(visible.scope).Select("id, "+match+" AS score", query).
+		Where("deleted_on = ? AND "+match, 0, query).
+		Order("score DESC, id DESC").Offset(offset).Limit(limit).Scan(&scores).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+
+	return scores, total, nil
+}
+
+// addArticleFulltextIndex create the FULLTEXT index SearchArticles relies
+// on. The ngram parser also splits Chinese text, which has no spaces.
+func addArticleFulltextIndex() error {
+	table := db.NewScope(&Article{}).TableName()
+
+	var count int
+	err := db.Raw("SELECT COUNT(*) FROM information_schema.statistics"+
+		" WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?", table, articleFulltextIndex).
+		Row().Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return db.Exec("ALTER TABLE `" + table + "` ADD FULLTEXT INDEX " + articleFulltextIndex +
+		" (`title`, `desc`, `content`) WITH PARSER ngram").Error
+}
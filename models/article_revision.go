@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ArticleRevision is an immutable snapshot of the title, desc and content of
+// an article, one is written when the article is added and on every edit.
+// Revision numbers count from 1 per article.
+type ArticleRevision struct {
+	Model
+
+	ArticleID int    `json:"article_id" gorm:"unique_index:idx_article_revision"`
+	Revision  int    `json:"revision" gorm:"unique_index:idx_article_revision"`
+	Title     string `json:"title" gorm:"size:100"`
+	Desc      string `json:"desc"`
+	Content   string `json:"content" gorm:"type:text"`
+	// AuthorID is the Auth.ID of the editor, 0 when unknown
+	AuthorID int    `json:"author_id"`
+	Author   string `json:"author" gorm:"size:100"`
+	// RestoredFrom is the revision an edit restored, 0 for a plain edit
+	RestoredFrom int `json:"restored_from"`
+}
+
+func GetArticleRevisionTotal(articleID int) (int, error) {
+	var count int
+	if err := db.Model(&ArticleRevision{}).Where("article_id = ?", articleID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetArticleRevisions return a page of the revisions of the article, latest first
+func GetArticleRevisions(articleID, pageNum, pageSize int) ([]*ArticleRevision, error) {
+	var revisions []*ArticleRevision
+	err := db.Where("article_id = ?", articleID).Order("revision desc").
+		Offset(pageNum).Limit(pageSize).Find(&revisions).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func GetArticleRevision(articleID, revision int) (*ArticleRevision, error) {
+	var rev ArticleRevision
+	err := db.Where("article_id = ? AND revision = ?", articleID, revision).First(&rev).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// addArticleRevision snapshot the article as its next revision. tx must hold
+// the lock on the article row so concurrent edits get distinct numbers.
+func addArticleRevision(tx *gorm.DB, article *Article, authorID int, author string, restoredFrom int) error {
+	var latest struct{ Revision int }
+	err := tx.Model(&ArticleRevision{}).Select("COALESCE(MAX(revision), 0) AS revision").
+		Where("article_id = ?", article.ID).Scan(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&ArticleRevision{
+		ArticleID:    article.ID,
+		Revision:     latest.Revision + 1,
+		Title:        article.Title,
+		Desc:         article.Desc,
+		Content:      article.Content,
+		AuthorID:     authorID,
+		Author:       author,
+		RestoredFrom: restoredFrom,
+	}).Error
+}
+
+// addBaseArticleRevision snapshot an article written before revisions were
+// kept, so its first edit can still be diffed and undone
+func addBaseArticleRevision(tx *gorm.DB, article *Article) error {
+	var count int
+	if err := tx.Model(&ArticleRevision{}).Where("article_id = ?", article.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	author, authorID := article.CreatedBy, article.CreatedByID
+	if article.ModifiedBy != "" && article.ModifiedBy != article.CreatedBy {
+		author, authorID = article.ModifiedBy, 0
+	}
+	base := ArticleRevision{
+		ArticleID: article.ID,
+		Revision:  1,
+		Title:     article.Title,
+		Desc:      article.Desc,
+		Content:   article.Content,
+		AuthorID:  authorID,
+		Author:    author,
+	}
+	base.CreatedOn = article.ModifiedOn
+	if base.CreatedOn == 0 {
+		base.CreatedOn = article.CreatedOn
+	}
+
+	return tx.Create(&base).Error
+}
@@ -0,0 +1,85 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// ArticleTag is the join table between articles and their tags
+type ArticleTag struct {
+	ArticleID int `json:"article_id" gorm:"primary_key;auto_increment:false"`
+	TagID     int `json:"tag_id" gorm:"primary_key;auto_increment:false;index"`
+}
+
+// TagFilter restrict articles to those having any, or with All every, tag of IDs
+type TagFilter struct {
+	IDs []int
+	All bool
+}
+
+// scope apply the filter to a query on articles, nil filters nothing
+func (f *TagFilter) scope(db *gorm.DB) *gorm.DB {
+	if f == nil || len(f.IDs) == 0 {
+		return db
+	}
+
+	joinTable := db.NewScope(&ArticleTag{}).TableName()
+	if !f.All {
+		return db.Where("id IN (SELECT article_id FROM "+joinTable+" WHERE tag_id IN (?))", f.IDs)
+	}
+
+	return db.Where("id IN (SELECT article_id FROM "+joinTable+" WHERE tag_id IN (?)"+
+		" GROUP BY article_id HAVING COUNT(DISTINCT tag_id) = ?)", f.IDs, len(uniqueInts(f.IDs)))
+}
+
+func replaceArticleTags(tx *gorm.DB, articleID int, tagIDs []int) error {
+	if err := tx.Where("article_id = ?", articleID).Delete(ArticleTag{}).Error; err != nil {
+		return err
+	}
+
+	for _, tagID := range uniqueInts(tagIDs) {
+		if err := tx.Create(&ArticleTag{ArticleID: articleID, TagID: tagID}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteArticleTagsByTag remove the tag from every article. The articles it
+// was the primary tag of fall back to another of their tags, or none.
+func DeleteArticleTagsByTag(tagID int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tagID).Delete(ArticleTag{}).Error; err != nil {
+			return err
+		}
+
+		articleTable := tx.NewScope(&Article{}).TableName()
+		joinTable := tx.NewScope(&ArticleTag{}).TableName()
+		return tx.Model(&Article{}).Where("tag_id = ?", tagID).UpdateColumn("tag_id", gorm.Expr(
+			"COALESCE((SELECT MIN(tag_id) FROM "+joinTable+" WHERE article_id = "+articleTable+".id), 0)")).Error
+	})
+}
+
+// backfillArticleTags copy the single tag_id of the articles without any
+// row in the join table into it, unless that tag was deleted
+func backfillArticleTags() error {
+	articleTable := db.NewScope(&Article{}).TableName()
+	tagTable := db.NewScope(&Tag{}).TableName()
+	joinTable := db.NewScope(&ArticleTag{}).TableName()
+
+	return db.Exec("INSERT INTO " + joinTable + " (article_id, tag_id)" +
+		" SELECT a.id, a.tag_id FROM " + articleTable + " a" +
+		" JOIN " + tagTable + " t ON t.id = a.tag_id AND t.deleted_on = 0" +
+		" WHERE NOT EXISTS (SELECT 1 FROM " + joinTable + " j WHERE j.article_id = a.id)").Error
+}
+
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	unique := make([]int, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
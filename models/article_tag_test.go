@@ -0,0 +1,100 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// articleTagIDs return the tag ids in the join table of every article
+func articleTagIDs(t *testing.T) map[int][]int {
+	var rows []ArticleTag
+	if err := db.Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[int][]int)
+	for _, row := range rows {
+		ids[row.ArticleID] = append(ids[row.ArticleID], row.TagID)
+	}
+	for _, tagIDs := range ids {
+		sort.Ints(tagIDs)
+	}
+
+	return ids
+}
+
+func TestBackfillArticleTags(t *testing.T) {
+	conn := useTestDB(t, &Article{}, &Tag{}, &ArticleTag{})
+
+	for _, tag := range []*Tag{{Name: "go"}, {Name: "db"}, {Name: "gone", Model: Model{DeletedOn: 1}}} {
+		if err := conn.Create(tag).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, article := range []*Article{
+		{Title: "single tag", TagID: 1},
+		{Title: "tags set since", TagID: 1},
+		{Title: "deleted tag", TagID: 3},
+		{Title: "no tag"},
+	} {
+		if err := conn.Create(article).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the tags of article 2 were edited to db alone after tag_ids came in
+	if err := conn.Create(&ArticleTag{ArticleID: 2, TagID: 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := backfillArticleTags(); err != nil {
+			t.Fatalf("backfillArticleTags() error = %v", err)
+		}
+	}
+
+	want := map[int][]int{1: {1}, 2: {2}}
+	if got := articleTagIDs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("backfillArticleTags() join rows = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteArticleTagsByTag(t *testing.T) {
+	conn := useTestDB(t, &Article{}, &ArticleTag{})
+
+	articles := []*Article{
+		{Title: "primary with others", TagID: 1},
+		{Title: "primary alone", TagID: 1},
+		{Title: "secondary", TagID: 2},
+	}
+	for _, article := range articles {
+		if err := conn.Create(article).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, row := range []ArticleTag{{1, 1}, {1, 3}, {1, 2}, {2, 1}, {3, 2}, {3, 1}} {
+		if err := conn.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteArticleTagsByTag(1); err != nil {
+		t.Fatalf("DeleteArticleTagsByTag() error = %v", err)
+	}
+
+	wantTagID := []int{2, 0, 2}
+	for i, article := range articles {
+		var got Article
+		if err := conn.Where("id = ?", article.ID).First(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.TagID != wantTagID[i] {
+			t.Errorf("%s: tag_id = %d, want %d", article.Title, got.TagID, wantTagID[i])
+		}
+	}
+
+	want := map[int][]int{1: {2, 3}, 3: {2}}
+	if got := articleTagIDs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("DeleteArticleTagsByTag() join rows = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ArticleTransition record one change of the state of an article and who made it
+type ArticleTransition struct {
+	Model
+
+	ArticleID int `json:"article_id" gorm:"index"`
+	FromState int `json:"from_state"`
+	ToState   int `json:"to_state"`
+	PublishAt int `json:"publish_at"`
+	// ActorID is the Auth.ID of the caller, 0 for the scheduler
+	ActorID int    `json:"actor_id"`
+	Actor   string `json:"actor" gorm:"size:100"`
+}
+
+// TransitionArticle move the article from state from to state to and record
+// the transition. It returns false, changing nothing, when the article is no
+// longer in state from, so concurrent transitions can't both succeed.
+func TransitionArticle(id, from, to, publishAt, actorID int, actor string) (bool, error) {
+	moved := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Article{}).Where("id = ? AND state = ? AND deleted_on = ?", id, from, 0).
+			Updates(map[string]interface{}{"state": to, "publish_at": publishAt, "modified_by": actor})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		moved = true
+		return tx.Create(&ArticleTransition{
+			ArticleID: id,
+			FromState: from,
+			ToState:   to,
+			PublishAt: publishAt,
+			ActorID:   actorID,
+			Actor:     actor,
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return moved, nil
+}
+
+// GetArticleTransitions return the state history of the article, latest first
+func GetArticleTransitions(articleID int) ([]*ArticleTransition, error) {
+	var transitions []*ArticleTransition
+	err := db.Where("article_id = ?", articleID).Order("id desc").Find(&transitions).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// GetDueArticles return up to limit scheduled articles whose publish_at is not after now
+func GetDueArticles(now, limit int) ([]*Article, error) {
+	var articles []*Article
+	err := db.Select("id, publish_at").
+		Where("state = ? AND publish_at <= ? AND deleted_on = ?", ARTICLE_STATE_SCHEDULED, now, 0).
+		Order("publish_at").Limit(limit).Find(&articles).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return articles, nil
+}
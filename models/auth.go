@@ -5,12 +5,76 @@ import "github.com/jinzhu/gorm"
 type Auth struct {
 	ID       int    `gorm:"primary_key" json:"id"`
 	Username string `json:"username"`
-	Password string `json:"password"`
+	Password string `json:"-"`
+	Email    string `json:"email" gorm:"size:100;index"`
+	Role     string `json:"role" gorm:"size:20;default:'reader'"`
+	State    int    `json:"state" gorm:"default:1"`
+
+	TotpSecret   string `json:"-" gorm:"size:64"`
+	TotpEnabled  bool   `json:"totp_enabled"`
+	TotpLastStep int64  `json:"-"`
+
+	// TokensRevokedAt is when every token of the user was last revoked, in
+	// unix milliseconds, so processes without a shared denylist learn of it
+	TokensRevokedAt int64 `json:"-" gorm:"not null;default:0"`
+}
+
+const (
+	AUTH_STATE_DISABLED = 0
+	AUTH_STATE_ACTIVE   = 1
+)
+
+func GetAuth(id int) (*Auth, error) {
+	var auth Auth
+	err := db.Where("id = ?", id).First(&auth).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &auth, nil
 }
 
-func CheckAuth(username, password string) (bool, error) {
+func GetAuthByUsername(username string) (*Auth, error) {
 	var auth Auth
-	err := db.Select("id").Where(Auth{Username: username, Password: password}).First(&auth).Error
+	err := db.Where("username = ?", username).First(&auth).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &auth, nil
+}
+
+func GetAuths() ([]*Auth, error) {
+	var auths []*Auth
+	if err := db.Find(&auths).Error; err != nil {
+		return nil, err
+	}
+
+	return auths, nil
+}
+
+func GetAuthList(pageNum int, pageSize int, maps interface{}) ([]*Auth, error) {
+	var auths []*Auth
+	err := db.Where(maps).Offset(pageNum).Limit(pageSize).Find(&auths).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return auths, nil
+}
+
+func GetAuthTotal(maps interface{}) (int, error) {
+	var count int
+	if err := db.Model(&Auth{}).Where(maps).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func ExistAuthByID(id int) (bool, error) {
+	var auth Auth
+	err := db.Select("id").Where("id = ?", id).First(&auth).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return false, err
 	}
@@ -21,3 +85,104 @@ func CheckAuth(username, password string) (bool, error) {
 
 	return false, nil
 }
+
+func ExistAuthByUsername(username string) (bool, error) {
+	var auth Auth
+	err := db.Select("id").Where("username = ?", username).First(&auth).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+
+	if auth.ID > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func AddAuth(username, password, email, role string) error {
+	auth := &Auth{
+		Username: username,
+		Password: password,
+		Email:    email,
+		Role:     role,
+		State:    AUTH_STATE_ACTIVE,
+	}
+
+	if err := db.Create(auth).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func EditAuth(id int, data interface{}) error {
+	if err := db.Model(&Auth{}).Where("id = ?", id).Updates(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func DeleteAuth(id int) error {
+	if err := db.Where("id = ?", id).Delete(&Auth{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func UpdateAuthPassword(id int, password string) error {
+	if err := db.Model(&Auth{}).Where("id = ?", id).Update("password", password).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func UpdateAuthRole(id int, role string) error {
+	if err := db.Model(&Auth{}).Where("id = ?", id).Update("role", role).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func UpdateAuthTotp(id int, data map[string]interface{}) error {
+	if err := db.Model(&Auth{}).Where("id = ?", id).Updates(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseAuthTotpStep record step as the last accepted TOTP time step, returning
+// false when a concurrent request already used it or a later one
+func UseAuthTotpStep(id int, step int64) (bool, error) {
+	res := db.Model(&Auth{}).Where("id = ? AND totp_last_step < ?", id, step).UpdateColumn("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+// RevokeAuthTokensAt record that every token issued to the user before at,
+// in unix milliseconds, is revoked
+func RevokeAuthTokensAt(id int, at int64) error {
+	return db.Model(&Auth{}).Where("id = ? AND tokens_revoked_at < ?", id, at).UpdateColumn("tokens_revoked_at", at).Error
+}
+
+// GetTokenRevocationsSince list the users whose tokens were revoked after
+// since, in unix milliseconds
+func GetTokenRevocationsSince(since int64) ([]*Auth, error) {
+	var auths []*Auth
+	err := db.Select("id, tokens_revoked_at").Where("tokens_revoked_at > ?", since).Find(&auths).Error
+
+	return auths, err
+}
+
+// MigrateAuthPasswordColumn widen the password column so it can hold a hash
+func MigrateAuthPasswordColumn() error {
+	return db.Model(&Auth{}).ModifyColumn("password", "varchar(255) DEFAULT ''").Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// AuthIdentity link an external identity provider subject to a local Auth
+type AuthIdentity struct {
+	Model
+
+	AuthID   int    `json:"auth_id" gorm:"index"`
+	Provider string `json:"provider" gorm:"size:50;unique_index:idx_provider_subject"`
+	Subject  string `json:"subject" gorm:"size:191;unique_index:idx_provider_subject"`
+}
+
+func GetAuthIdentity(provider, subject string) (*AuthIdentity, error) {
+	var identity AuthIdentity
+	err := db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// AddAuthWithIdentity create the local Auth of an external identity on its
+// first login, auth.ID is set on success
+func AddAuthWithIdentity(auth *Auth, provider, subject string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(auth).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&AuthIdentity{
+			AuthID:   auth.ID,
+			Provider: provider,
+			Subject:  subject,
+		}).Error
+	})
+}
+
+func DeleteAuthIdentities(authID int) error {
+	return db.Where("auth_id = ?", authID).Delete(AuthIdentity{}).Error
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration record a one-time data migration that has been applied, so
+// Migrate doesn't undo what admins changed since
+type Migration struct {
+	Name      string `json:"name" gorm:"primary_key;size:100"`
+	AppliedOn int    `json:"applied_on"`
+}
+
+// runOnce run fn unless the migration name has already been applied, and
+// record it in the same transaction
+func runOnce(name string, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var migration Migration
+		err := tx.Where("name = ?", name).First(&migration).Error
+		if err == nil {
+			return nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		// a concurrent Migrate that got here first makes this fail on the key
+		return tx.Create(&Migration{Name: name, AppliedOn: int(time.Now().Unix())}).Error
+	})
+}
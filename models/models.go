@@ -32,6 +32,17 @@ func Setup() {
 		log.Fatalf("models.Setup err: %v", err)
 	}
 
+	configure()
+}
+
+// UseDB run the models on an already opened connection instead of the
+// configured database, e.g. an in-memory SQLite database in tests
+func UseDB(conn *gorm.DB) {
+	db = conn
+	configure()
+}
+
+func configure() {
 	gorm.DefaultTableNameHandler = func(db *gorm.DB, defaultTableName string) string {
 		return settings.DatabaseSetting.TablePrefix + defaultTableName
 	}
@@ -44,6 +55,62 @@ func Setup() {
 	db.DB().SetMaxOpenConns(100)
 }
 
+// Migrate create or update the tables that are managed by the application
+func Migrate() error {
+	err := db.AutoMigrate(
+		&Migration{},
+		&Auth{},
+		&ApiKey{},
+		&RefreshToken{},
+		&RolePermission{},
+		&RecoveryCode{},
+		&PasswordReset{},
+		&AuthIdentity{},
+		&Session{},
+		&Tag{},
+		&Article{},
+		&ArticleTag{},
+		&ArticleRevision{},
+		&ArticleTransition{},
+		&SlugRedirect{},
+	).Error
+	if err != nil {
+		return err
+	}
+
+	if err := seedRolePermissions(); err != nil {
+		return err
+	}
+	if err := seedNewPermissions("seed_article_permissions", "article:delete", "article:manage", "article:publish"); err != nil {
+		return err
+	}
+
+	if err := backfillArticleAuthors(); err != nil {
+		return err
+	}
+	if err := backfillArticleTags(); err != nil {
+		return err
+	}
+	if err := backfillSlugs(); err != nil {
+		return err
+	}
+
+	if settings.AppSetting.SearchIndex == "mysql" {
+		return addArticleFulltextIndex()
+	}
+	return nil
+}
+
+// forUpdate lock the rows tx selects until it ends. SQLite has no row locks
+// and serializes the write transactions instead.
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialect().GetName() == "sqlite3" {
+		return tx
+	}
+
+	return tx.Set("gorm:query_option", "FOR UPDATE")
+}
+
 func CloseDB() {
 	defer db.Close()
 }
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/miaozhang/webservice/internal/testdb"
+)
+
+// useTestDB run the models on an empty in-memory database with the tables
+// of values
+func useTestDB(t *testing.T, values ...interface{}) *gorm.DB {
+	return testdb.Open(t, UseDB, values...)
+}
+
+func TestMigrateBaselineSchema(t *testing.T) {
+	conn := useTestDB(t)
+	// the tables as they were before Migrate existed
+	for _, stmt := range []string{
+		"CREATE TABLE auth (id integer primary key autoincrement, username varchar(255), password varchar(255))",
+		"CREATE TABLE tag (id integer primary key autoincrement, created_on integer, modified_on integer, deleted_on integer," +
+			" name varchar(255), created_by varchar(255), modified_by varchar(255), state integer)",
+		"CREATE TABLE article (id integer primary key autoincrement, created_on integer, modified_on integer, deleted_on integer," +
+			" tag_id integer, title varchar(255), \"desc\" varchar(255), content varchar(255), created_by varchar(255)," +
+			" modified_by varchar(255), state integer)",
+		"INSERT INTO auth (username, password) VALUES ('alice', 'secret')",
+		"INSERT INTO tag (created_on, modified_on, deleted_on, name, created_by, modified_by, state) VALUES (0, 0, 0, 'Go Tips', 'alice', '', 1)",
+		"INSERT INTO article (created_on, modified_on, deleted_on, tag_id, title, \"desc\", content, created_by, modified_by, state)" +
+			" VALUES (0, 0, 0, 1, 'Hello World', '', '', 'alice', '', 1)",
+	} {
+		if err := conn.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	// a second run on the migrated schema changes nothing
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate() again error = %v", err)
+	}
+
+	tag, err := GetTagBySlug("go-tips")
+	if err != nil {
+		t.Fatalf("GetTagBySlug() error = %v", err)
+	}
+	if tag.ID != 1 {
+		t.Errorf("GetTagBySlug() = tag %d, want the backfilled tag 1", tag.ID)
+	}
+	if err := AddTag("Go Tips!", 1, "alice"); err != nil {
+		t.Fatalf("AddTag() error = %v", err)
+	}
+	if tag, err := GetTagBySlug("go-tips-2"); err != nil || tag.ID == 0 {
+		t.Errorf("GetTagBySlug() of the new tag = %v, %v", tag, err)
+	}
+
+	var article Article
+	if err := conn.First(&article, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if article.Slug != "hello-world" || article.CreatedByID != 1 {
+		t.Errorf("migrated article slug = %q, created_by_id = %d, want hello-world by 1", article.Slug, article.CreatedByID)
+	}
+	auth, err := GetAuthByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.State != AUTH_STATE_ACTIVE || auth.Role != ROLE_READER {
+		t.Errorf("migrated auth state = %d, role = %q, want an active reader", auth.State, auth.Role)
+	}
+
+	var tagIDs []int
+	if err := conn.Model(&ArticleTag{}).Where("article_id = ?", 1).Pluck("tag_id", &tagIDs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tagIDs) != 1 || tagIDs[0] != 1 {
+		t.Errorf("migrated article tags = %v, want [1]", tagIDs)
+	}
+}
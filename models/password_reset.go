@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type PasswordReset struct {
+	Model
+
+	AuthID    int    `json:"auth_id" gorm:"index"`
+	TokenHash string `json:"-" gorm:"size:64;unique_index"`
+	ExpiresOn int    `json:"expires_on"`
+	UsedOn    int    `json:"used_on"`
+}
+
+// AddPasswordReset store a new reset token and invalidate the older ones of the user
+func AddPasswordReset(authID int, tokenHash string, expiresOn int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&PasswordReset{}).Where("auth_id = ? AND used_on = ?", authID, 0).
+			Update("used_on", time.Now().Unix()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&PasswordReset{
+			AuthID:    authID,
+			TokenHash: tokenHash,
+			ExpiresOn: expiresOn,
+		}).Error
+	})
+}
+
+func GetPasswordResetByHash(tokenHash string) (*PasswordReset, error) {
+	var reset PasswordReset
+	err := db.Where("token_hash = ?", tokenHash).First(&reset).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// UsePasswordReset mark the token as used, returning false when it already
+// was used or has expired
+func UsePasswordReset(id int) (bool, error) {
+	now := time.Now().Unix()
+	res := db.Model(&PasswordReset{}).Where("id = ? AND used_on = ? AND expires_on >= ?", id, 0, now).
+		Update("used_on", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RecoveryCode struct {
+	ID       int    `gorm:"primary_key" json:"id"`
+	AuthID   int    `json:"auth_id" gorm:"index"`
+	CodeHash string `json:"-" gorm:"size:255"`
+	UsedOn   int    `json:"used_on"`
+}
+
+// ReplaceRecoveryCodes drop the recovery codes of the user and store codeHashes instead
+func ReplaceRecoveryCodes(authID int, codeHashes []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("auth_id = ?", authID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		for _, hash := range codeHashes {
+			if err := tx.Create(&RecoveryCode{AuthID: authID, CodeHash: hash}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func GetUnusedRecoveryCodes(authID int) ([]*RecoveryCode, error) {
+	var codes []*RecoveryCode
+	err := db.Where("auth_id = ? AND used_on = ?", authID, 0).Find(&codes).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode mark the code as used, returning false when it already was
+func UseRecoveryCode(id int) (bool, error) {
+	res := db.Model(&RecoveryCode{}).Where("id = ? AND used_on = ?", id, 0).Update("used_on", time.Now().Unix())
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func DeleteRecoveryCodes(authID int) error {
+	if err := db.Where("auth_id = ?", authID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
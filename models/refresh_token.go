@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RefreshToken struct {
+	Model
+
+	AuthID    int    `json:"auth_id" gorm:"index"`
+	FamilyID  string `json:"family_id" gorm:"size:64;index"`
+	TokenHash string `json:"-" gorm:"size:64;unique_index"`
+	ExpiresOn int    `json:"expires_on"`
+	RotatedOn int    `json:"rotated_on"`
+	RevokedOn int    `json:"revoked_on"`
+}
+
+func AddRefreshToken(authID int, familyID, tokenHash string, expiresOn int) error {
+	token := &RefreshToken{
+		AuthID:    authID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresOn: expiresOn,
+	}
+
+	if err := db.Create(token).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetRefreshTokenByHash(tokenHash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken mark the token as used, returning false when another
+// request already rotated or revoked it
+func RotateRefreshToken(id int) (bool, error) {
+	res := db.Model(&RefreshToken{}).Where("id = ? AND rotated_on = ? AND revoked_on = ?", id, 0, 0).
+		Update("rotated_on", time.Now().Unix())
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func RevokeRefreshTokenFamily(familyID string) error {
+	err := db.Model(&RefreshToken{}).Where("family_id = ? AND revoked_on = ?", familyID, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func RevokeRefreshTokensByAuth(authID int) error {
+	err := db.Model(&RefreshToken{}).Where("auth_id = ? AND revoked_on = ?", authID, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ROLE_ADMIN  = "admin"
+	ROLE_EDITOR = "editor"
+	ROLE_AUTHOR = "author"
+	ROLE_READER = "reader"
+)
+
+var Roles = []string{ROLE_ADMIN, ROLE_EDITOR, ROLE_AUTHOR, ROLE_READER}
+
+// DefaultRolePermissions is written to the role_permission table by Migrate
+// when the table is still empty
+var DefaultRolePermissions = map[string][]string{
+	ROLE_ADMIN: {
+		"tag:read", "tag:write", "tag:delete",
+		"article:read", "article:write", "article:delete", "article:manage", "article:publish",
+		"user:manage",
+	},
+	ROLE_EDITOR: {
+		"tag:read", "tag:write", "tag:delete",
+		"article:read", "article:write", "article:delete", "article:manage", "article:publish",
+	},
+	ROLE_AUTHOR: {
+		"tag:read",
+		"article:read", "article:write", "article:delete",
+	},
+	ROLE_READER: {
+		"tag:read",
+		"article:read",
+	},
+}
+
+type RolePermission struct {
+	ID         int    `gorm:"primary_key" json:"id"`
+	Role       string `json:"role" gorm:"size:20;unique_index:idx_role_permission"`
+	Permission string `json:"permission" gorm:"size:50;unique_index:idx_role_permission"`
+}
+
+func IsRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ExistRolePermission(role, permission string) (bool, error) {
+	var rp RolePermission
+	err := db.Select("id").Where("role = ? AND permission = ?", role, permission).First(&rp).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+
+	if rp.ID > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func GetRolePermissions(role string) ([]string, error) {
+	var permissions []string
+	if err := db.Model(&RolePermission{}).Where("role = ?", role).Pluck("permission", &permissions).Error; err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// seedRolePermissions fill the role_permission table with DefaultRolePermissions if it is empty
+func seedRolePermissions() error {
+	var count int
+	if err := db.Model(&RolePermission{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for role, permissions := range DefaultRolePermissions {
+		for _, permission := range permissions {
+			if err := db.Create(&RolePermission{Role: role, Permission: permission}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedNewPermissions grant permissions added to DefaultRolePermissions after
+// the table was first seeded: every default role holding one of them gets
+// the (role, permission) pair it is still missing. It runs once per name, so
+// a grant an admin revoked afterwards stays revoked.
+func seedNewPermissions(name string, permissions ...string) error {
+	return runOnce(name, func(tx *gorm.DB) error {
+		for _, permission := range permissions {
+			for role, granted := range DefaultRolePermissions {
+				for _, p := range granted {
+					if p != permission {
+						continue
+					}
+					var count int
+					err := tx.Model(&RolePermission{}).Where("role = ? AND permission = ?", role, permission).Count(&count).Error
+					if err != nil {
+						return err
+					}
+					if count > 0 {
+						continue
+					}
+					if err := tx.Create(&RolePermission{Role: role, Permission: permission}).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSeedNewPermissions(t *testing.T) {
+	conn := useTestDB(t, &RolePermission{})
+
+	// a database seeded before authors could delete their articles
+	for _, rp := range []RolePermission{
+		{Role: ROLE_ADMIN, Permission: "article:delete"},
+		{Role: ROLE_EDITOR, Permission: "article:delete"},
+		{Role: ROLE_AUTHOR, Permission: "article:write"},
+	} {
+		if err := conn.Create(&rp).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if granted, err := ExistRolePermission(ROLE_AUTHOR, "article:delete"); err != nil || !granted {
+		t.Fatalf("Migrate() didn't grant the new article:delete to authors: %v", err)
+	}
+
+	// an admin takes the new grant away again
+	err := conn.Where("role = ? AND permission = ?", ROLE_AUTHOR, "article:delete").Delete(RolePermission{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate() again error = %v", err)
+	}
+
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{ROLE_AUTHOR, "article:delete", false},
+		{ROLE_ADMIN, "article:manage", true},
+		{ROLE_EDITOR, "article:manage", true},
+		{ROLE_EDITOR, "article:publish", true},
+		{ROLE_AUTHOR, "article:manage", false},
+		{ROLE_READER, "article:delete", false},
+		// not one of the new permissions
+		{ROLE_ADMIN, "user:manage", false},
+	}
+	for _, tt := range tests {
+		got, err := ExistRolePermission(tt.role, tt.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ExistRolePermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+
+	var count int
+	if err := conn.Model(&RolePermission{}).Where("permission = ?", "article:publish").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("article:publish granted %d times, want 2", count)
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Session is one login of a user on a device. It lives as long as the
+// refresh token family it was started with.
+type Session struct {
+	Model
+
+	AuthID     int    `json:"-" gorm:"index"`
+	FamilyID   string `json:"-" gorm:"size:64;unique_index"`
+	UserAgent  string `json:"user_agent" gorm:"size:255"`
+	IP         string `json:"ip" gorm:"size:45"`
+	LastSeenOn int    `json:"last_seen_on"`
+	ExpiresOn  int    `json:"expires_on"`
+	RevokedOn  int    `json:"-"`
+}
+
+func AddSession(authID int, familyID, userAgent, ip string, expiresOn int) error {
+	session := &Session{
+		AuthID:     authID,
+		FamilyID:   familyID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenOn: int(time.Now().Unix()),
+		ExpiresOn:  expiresOn,
+	}
+
+	if err := db.Create(session).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetSession(id int) (*Session, error) {
+	var session Session
+	err := db.Where("id = ?", id).First(&session).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetActiveSessions return the sessions of the user that are neither revoked nor expired
+func GetActiveSessions(authID int) ([]*Session, error) {
+	var sessions []*Session
+	err := db.Where("auth_id = ? AND revoked_on = ? AND expires_on > ?", authID, 0, time.Now().Unix()).
+		Order("last_seen_on desc").Find(&sessions).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession record activity on the session, ip is left alone when empty
+func TouchSession(familyID, ip string, lastSeenOn int) error {
+	data := map[string]interface{}{"last_seen_on": lastSeenOn}
+	if ip != "" {
+		data["ip"] = ip
+	}
+
+	return db.Model(&Session{}).Where("family_id = ? AND revoked_on = ?", familyID, 0).UpdateColumns(data).Error
+}
+
+// ExtendSession move the expiry of the session along with its latest refresh token
+func ExtendSession(familyID string, expiresOn int) error {
+	return db.Model(&Session{}).Where("family_id = ? AND revoked_on = ?", familyID, 0).
+		UpdateColumn("expires_on", expiresOn).Error
+}
+
+func RevokeSession(familyID string) error {
+	return db.Model(&Session{}).Where("family_id = ? AND revoked_on = ?", familyID, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+}
+
+// GetSessionsRevokedSince return the family ids of the sessions revoked at
+// or after since, in unix seconds
+func GetSessionsRevokedSince(since int) ([]string, error) {
+	var familyIDs []string
+	err := db.Model(&Session{}).Where("revoked_on >= ? AND revoked_on > ?", since, 0).Pluck("family_id", &familyIDs).Error
+
+	return familyIDs, err
+}
+
+func RevokeSessionsByAuth(authID int) error {
+	return db.Model(&Session{}).Where("auth_id = ? AND revoked_on = ?", authID, 0).
+		Update("revoked_on", time.Now().Unix()).Error
+}
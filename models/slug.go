@@ -0,0 +1,144 @@
+package models
+
+import (
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/miaozhang/webservice/util"
+)
+
+const (
+	SLUG_KIND_ARTICLE = "article"
+	SLUG_KIND_TAG     = "tag"
+
+	// slugMaxRunes leave room in the slug columns for the collision suffix
+	slugMaxRunes = 80
+)
+
+// SlugRedirect remember a former slug of an article or tag, so permalinks
+// keep working after a rename
+type SlugRedirect struct {
+	Model
+
+	Kind     string `json:"kind" gorm:"size:20;unique_index:idx_slug_redirect"`
+	Slug     string `json:"slug" gorm:"size:100;unique_index:idx_slug_redirect"`
+	TargetID int    `json:"target_id" gorm:"index"`
+}
+
+// GetSlugRedirect return the article or tag that formerly had the slug, 0 when none
+func GetSlugRedirect(kind, slug string) (int, error) {
+	var redirect SlugRedirect
+	err := db.Where("kind = ? AND slug = ?", kind, slug).First(&redirect).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, err
+	}
+
+	return redirect.TargetID, nil
+}
+
+// allocateSlug return a slug for the text that no other article or tag of
+// the kind has or had: its slug, else with the first free -2, -3... suffix
+func allocateSlug(tx *gorm.DB, kind, text string, targetID int) (string, error) {
+	base := util.Slugify(text, slugMaxRunes)
+	if base == "" {
+		base = kind
+	}
+
+	table := db.NewScope(&Article{}).TableName()
+	if kind == SLUG_KIND_TAG {
+		table = db.NewScope(&Tag{}).TableName()
+	}
+
+	var current, former []string
+	err := tx.Table(table).Where("(slug = ? OR slug LIKE ?) AND id != ?", base, base+"-%", targetID).
+		Pluck("slug", &current).Error
+	if err != nil {
+		return "", err
+	}
+	err = tx.Model(&SlugRedirect{}).Where("kind = ? AND (slug = ? OR slug LIKE ?) AND target_id != ?", kind, base, base+"-%", targetID).
+		Pluck("slug", &former).Error
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(current)+len(former))
+	for _, slug := range append(current, former...) {
+		taken[slug] = true
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug, nil
+}
+
+// renameSlug give the target a slug for its new text, keeping the old slug
+// as a redirect. The slug is kept when the text slugifies the same.
+func renameSlug(tx *gorm.DB, kind string, targetID int, oldSlug, oldText, newText string) (string, error) {
+	if oldSlug != "" && util.Slugify(oldText, slugMaxRunes) == util.Slugify(newText, slugMaxRunes) {
+		return oldSlug, nil
+	}
+
+	slug, err := allocateSlug(tx, kind, newText, targetID)
+	if err != nil || slug == oldSlug {
+		return slug, err
+	}
+
+	// renaming back to a former slug takes it out of the redirects
+	err = tx.Where("kind = ? AND slug = ? AND target_id = ?", kind, slug, targetID).Delete(SlugRedirect{}).Error
+	if err != nil {
+		return "", err
+	}
+	if oldSlug != "" {
+		err = tx.Create(&SlugRedirect{Kind: kind, Slug: oldSlug, TargetID: targetID}).Error
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return slug, nil
+}
+
+// DeleteSlugRedirects forget the former slugs of a deleted article or tag
+func DeleteSlugRedirects(kind string, targetID int) error {
+	return db.Where("kind = ? AND target_id = ?", kind, targetID).Delete(SlugRedirect{}).Error
+}
+
+// backfillSlugs give a slug to the articles and tags created before slugs
+// existed, then enforce their uniqueness
+func backfillSlugs() error {
+	var articles []*Article
+	if err := db.Select("id, title").Where("slug = ? OR slug IS NULL", "").Find(&articles).Error; err != nil {
+		return err
+	}
+	for _, article := range articles {
+		slug, err := allocateSlug(db, SLUG_KIND_ARTICLE, article.Title, article.ID)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&Article{}).Where("id = ?", article.ID).UpdateColumn("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+
+	var tags []*Tag
+	if err := db.Select("id, name").Where("slug = ? OR slug IS NULL", "").Find(&tags).Error; err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		slug, err := allocateSlug(db, SLUG_KIND_TAG, tag.Name, tag.ID)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&Tag{}).Where("id = ?", tag.ID).UpdateColumn("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Model(&Article{}).AddUniqueIndex("idx_article_slug", "slug").Error; err != nil {
+		return err
+	}
+	return db.Model(&Tag{}).AddUniqueIndex("idx_tag_slug", "slug").Error
+}
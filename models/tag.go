@@ -8,6 +8,7 @@ type Tag struct {
 	Model
 
 	Name       string `json:"name"`
+	Slug       string `json:"slug" gorm:"size:100"`
 	CreatedBy  string `json:"created_by"`
 	ModifiedBy string `json:"modified_by"`
 	State      int    `json:"state"`
@@ -66,6 +67,27 @@ func ExistTagByID(id int) (bool, error) {
 	return false, nil
 }
 
+// GetTagBySlug return the tag having the slug, its ID is 0 when none
+func GetTagBySlug(slug string) (*Tag, error) {
+	var tag Tag
+	err := db.Where("slug = ? AND deleted_on = ?", slug, 0).First(&tag).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func GetTag(id int) (*Tag, error) {
+	var tag Tag
+	err := db.Where("id = ? AND deleted_on = ?", id, 0).First(&tag).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
 func AddTag(name string, state int, createdBy string) error {
 	tag := &Tag{
 		Name:      name,
@@ -73,25 +95,49 @@ func AddTag(name string, state int, createdBy string) error {
 		CreatedBy: createdBy,
 	}
 
-	if err := db.Create(tag).Error; err != nil {
-		return err
-	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		slug, err := allocateSlug(tx, SLUG_KIND_TAG, name, 0)
+		if err != nil {
+			return err
+		}
+		tag.Slug = slug
 
-	return nil
+		return tx.Create(tag).Error
+	})
 }
 
-func EditTag(id int, data interface{}) error {
-	if err := db.Model(&Tag{}).Where("id = ? AND deleted_on = ? ", id, 0).Updates(data).Error; err != nil {
-		return err
-	}
-	return nil
+// EditTag update the tag, a new name gives it a new slug
+func EditTag(id int, data map[string]interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tag Tag
+		err := forUpdate(tx).Where("id = ? AND deleted_on = ? ", id, 0).First(&tag).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if name, ok := data["name"].(string); ok {
+			slug, err := renameSlug(tx, SLUG_KIND_TAG, id, tag.Slug, tag.Name, name)
+			if err != nil {
+				return err
+			}
+			data["slug"] = slug
+		}
+
+		return tx.Model(&Tag{}).Where("id = ? AND deleted_on = ? ", id, 0).Updates(data).Error
+	})
 }
 
 func DeleteTag(id int) error {
 	if err := db.Where("id = ?", id).Delete(&Tag{}).Error; err != nil {
 		return err
 	}
-	return nil
+	if err := DeleteSlugRedirects(SLUG_KIND_TAG, id); err != nil {
+		return err
+	}
+	return DeleteArticleTagsByTag(id)
 }
 
 func CleanAllTag() (bool, error) {
@@ -0,0 +1,343 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+const (
+	// discoveryTTL is how long the discovery document and the provider keys are cached
+	discoveryTTL = time.Hour
+	// keyRefreshInterval limit how often an unknown kid triggers a new JWKS fetch
+	keyRefreshInterval = 10 * time.Second
+	// clockSkew is the leeway allowed on the ID token time claims
+	clockSkew = time.Minute
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oidc provider")
+	ErrIDTokenInvalid  = errors.New("id token is invalid")
+
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Provider run the authorization code flow with PKCE against one OpenID
+// Connect provider. The discovery document and keys are fetched lazily so an
+// unreachable provider doesn't keep the server from starting.
+type Provider struct {
+	Name   string
+	Config *settings.Oidc
+
+	mu           sync.Mutex
+	discovery    *discovery
+	discoveredAt time.Time
+	keys         map[string]interface{}
+	keysFetchAt  time.Time
+}
+
+var providers = map[string]*Provider{}
+
+// Setup create the providers configured in the [oidc.<name>] sections
+func Setup() {
+	providers = make(map[string]*Provider, len(settings.OidcSettings))
+	for name, config := range settings.OidcSettings {
+		providers[name] = &Provider{Name: name, Config: config}
+	}
+}
+
+func GetProvider(name string) (*Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return p, nil
+}
+
+// IDToken is the verified content of an ID token
+type IDToken struct {
+	Subject string
+	Claims  jwt.MapClaims
+}
+
+// StringClaim return the claim as a string, empty when missing
+func (t *IDToken) StringClaim(name string) string {
+	value, _ := t.Claims[name].(string)
+	return value
+}
+
+// NewCodeVerifier return a random RFC 7636 code verifier
+func NewCodeVerifier() (string, error) {
+	return util.RandomToken(32)
+}
+
+// CodeChallenge derive the S256 code challenge of verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL return the authorization endpoint URL the browser is sent to
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientId},
+		"redirect_uri":          {p.Config.RedirectUrl},
+		"scope":                 {strings.Join(p.Config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeem the authorization code and return the verified ID token
+func (p *Provider) Exchange(code, verifier, nonce string) (*IDToken, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectUrl},
+		"client_id":     {p.Config.ClientId},
+		"code_verifier": {verifier},
+	}
+	if p.Config.ClientSecret != "" {
+		form.Set("client_secret", p.Config.ClientSecret)
+	}
+
+	resp, err := httpClient.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc %s: token endpoint returned %d: %s", p.Name, resp.StatusCode, body)
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc %s: token response has no id_token", p.Name)
+	}
+
+	return p.verify(token.IDToken, d.Issuer, nonce)
+}
+
+// verify check the ID token signature against the provider keys and its
+// issuer, audience, lifetime and nonce
+func (p *Provider) verify(raw, issuer, nonce string) (*IDToken, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(raw, p.verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrIDTokenInvalid
+	}
+
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true) ||
+		!claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), false) ||
+		!claims.VerifyNotBefore(now.Add(clockSkew).Unix(), false) {
+		return nil, ErrIDTokenInvalid
+	}
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return nil, ErrIDTokenInvalid
+	}
+	if !hasAudience(claims["aud"], p.Config.ClientId) {
+		return nil, ErrIDTokenInvalid
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, ErrIDTokenInvalid
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, ErrIDTokenInvalid
+	}
+
+	return &IDToken{Subject: subject, Claims: claims}, nil
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *Provider) verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *util.SigningMethodEdDSA:
+	default:
+		return nil, errors.New("unexpected signing method " + token.Method.Alg())
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := p.publicKey(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// publicKey return the provider key named kid, fetching the JWKS again when
+// the kid is unknown since the provider may have rotated its keys
+func (p *Provider) publicKey(kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.findKey(kid); ok && time.Since(p.keysFetchAt) < discoveryTTL {
+		return key, nil
+	}
+	if time.Since(p.keysFetchAt) >= keyRefreshInterval {
+		if err := p.fetchKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := p.findKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key " + kid)
+}
+
+// findKey look kid up, an empty kid matches when the provider has a single key
+func (p *Provider) findKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) fetchKeys() error {
+	d, err := p.discoveryLocked()
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []util.JSONWebKey `json:"keys"`
+	}
+	if err := getJSON(d.JwksURI, &jwks); err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysFetchAt = time.Now()
+	return nil
+}
+
+func (p *Provider) getDiscovery() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.discoveryLocked()
+}
+
+func (p *Provider) discoveryLocked() (*discovery, error) {
+	if p.discovery != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	issuer := strings.TrimSuffix(p.Config.Issuer, "/")
+	if err := getJSON(issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc %s: discovery issuer %q doesn't match %q", p.Name, d.Issuer, p.Config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return nil, fmt.Errorf("oidc %s: incomplete discovery document", p.Name)
+	}
+
+	p.discovery = &d
+	p.discoveredAt = time.Now()
+	return p.discovery, nil
+}
+
+func getJSON(url string, v interface{}) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
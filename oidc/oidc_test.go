@@ -0,0 +1,143 @@
+package oidc
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/miaozhang/webservice/oidc/oidctest"
+	"github.com/miaozhang/webservice/settings"
+)
+
+const (
+	testClientID    = "webservice"
+	testRedirectUrl = "http://127.0.0.1:8000/auth/oidc/mock/callback"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	mock, err := oidctest.NewProvider(testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+
+	return &Provider{Name: "mock", Config: &settings.Oidc{
+		Issuer:      mock.Issuer(),
+		ClientId:    testClientID,
+		RedirectUrl: testRedirectUrl,
+		Scopes:      []string{"openid"},
+	}}, mock
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectUrl,
+		"scope":                 "openid",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("AuthCodeURL() %s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge() = %q, want %q", got, want)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		tamper   func(claims jwt.MapClaims)
+		wantErr  bool
+	}{
+		{name: "valid"},
+		{name: "wrong code verifier", verifier: "other-verifier", wantErr: true},
+		{name: "wrong nonce", nonce: "other-nonce", wantErr: true},
+		{name: "missing nonce", tamper: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: true},
+		{name: "other audience", tamper: func(c jwt.MapClaims) { c["aud"] = "other-client" }, wantErr: true},
+		{name: "audience list", tamper: func(c jwt.MapClaims) { c["aud"] = []string{"other-client", testClientID} }},
+		{name: "other issuer", tamper: func(c jwt.MapClaims) { c["iss"] = "https://attacker.example" }, wantErr: true},
+		{name: "expired", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() }, wantErr: true},
+		{name: "expired within clock skew", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-clockSkew / 2).Unix() }},
+		{name: "missing expiry", tamper: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "issued in the future", tamper: func(c jwt.MapClaims) { c["iat"] = time.Now().Add(2 * clockSkew).Unix() }, wantErr: true},
+		{name: "missing subject", tamper: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, mock := newTestProvider(t)
+			mock.Tamper = tt.tamper
+
+			authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := mock.Authorize(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifier, nonce := "verifier", "nonce"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			idToken, err := p.Exchange(code, verifier, nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && idToken.Subject != mock.Subject {
+				t.Errorf("Exchange() subject = %q, want %q", idToken.Subject, mock.Subject)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	p, mock := newTestProvider(t)
+
+	authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := mock.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Exchange(code, "verifier", "nonce"); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if _, err := p.Exchange(code, "verifier", "nonce"); err == nil {
+		t.Error("Exchange() of a redeemed code succeeded")
+	}
+}
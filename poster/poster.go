@@ -0,0 +1,330 @@
+package poster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// renderVersion is part of the poster names, bump it whenever the layout
+// changes so stale posters aren't served
+const renderVersion = "1"
+
+// layout of the poster, in pixels
+const (
+	width        = 750
+	height       = 1334
+	margin       = 56
+	coverHeight  = 420
+	titleSize    = 44
+	titleLines   = 3
+	descSize     = 28
+	descLines    = 5
+	lineSpacing  = 1.5
+	qrCodeSize   = 280
+	qrCodeBottom = 96
+)
+
+// maxCoverPixels bound the size of the covers decoded, a small file can
+// declare a huge image that takes gigabytes to decode
+const maxCoverPixels = 40 * 1000 * 1000
+
+var errCoverTooLarge = errors.New("cover image is too large")
+
+var (
+	textColor = color.RGBA{R: 0x1f, G: 0x23, B: 0x28, A: 0xff}
+	descColor = color.RGBA{R: 0x6a, G: 0x73, B: 0x7d, A: 0xff}
+	fillColor = color.RGBA{R: 0xf0, G: 0xf2, B: 0xf5, A: 0xff}
+)
+
+// Poster is the content of an article poster
+type Poster struct {
+	Title string
+	Desc  string
+	// Cover is the content of the cover image, nil for none
+	Cover []byte
+	// Url is what the QR code links to
+	Url string
+}
+
+var (
+	fontLock   sync.Mutex
+	posterFont *sfnt.Font
+)
+
+// GetPosterPath get the save path of the posters, relative to RuntimeRootPath
+func GetPosterPath() string {
+	return settings.AppSetting.QrCodeSavePath
+}
+
+// GetPosterFullPath get the directory the posters are saved in
+func GetPosterFullPath() string {
+	return settings.AppSetting.RuntimeRootPath + GetPosterPath()
+}
+
+// GetPosterFullUrl get the public url of the poster saved under name
+func GetPosterFullUrl(name string) string {
+	return settings.AppSetting.PrefixUrl + "/" + GetPosterPath() + name
+}
+
+// GetPosterName get the name the poster is saved under: the hash of
+// everything drawn on it, so an unchanged article reuses its poster
+func (p *Poster) GetPosterName() string {
+	h := sha256.New()
+	for _, part := range []string{renderVersion, settings.AppSetting.PosterFont, p.Title, p.Desc, p.Url} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(p.Cover)
+
+	return "poster-" + hex.EncodeToString(h.Sum(nil)) + ".png"
+}
+
+// Generate render the poster unless it is saved already, and return the
+// name it is saved under
+func (p *Poster) Generate() (string, error) {
+	name := p.GetPosterName()
+	fullPath := GetPosterFullPath() + name
+	if !logging.CheckNotExist(fullPath) {
+		return name, nil
+	}
+
+	content, err := p.Render()
+	if err != nil {
+		return "", err
+	}
+	if err := save(content, fullPath); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// Render draw the poster as a PNG: the cover across the top, the title and
+// description below it and the QR code at the bottom
+func (p *Poster) Render() ([]byte, error) {
+	f, err := loadFont()
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+
+	coverRect := image.Rect(0, 0, width, coverHeight)
+	draw.Draw(canvas, coverRect, image.NewUniform(fillColor), image.Point{}, draw.Src)
+	if p.Cover != nil {
+		cover, err := decodeCover(p.Cover)
+		if err != nil {
+			// the poster is still worth having without its cover
+			logging.Warn("poster.Render decode cover fail", err)
+		} else {
+			xdraw.CatmullRom.Scale(canvas, coverRect, cover, coverCrop(cover.Bounds(), coverRect), draw.Src, nil)
+		}
+	}
+
+	y := coverHeight + margin
+	y, err = drawText(canvas, f, p.Title, titleSize, titleLines, textColor, y)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := drawText(canvas, f, p.Desc, descSize, descLines, descColor, y+descSize/2); err != nil {
+		return nil, err
+	}
+
+	qrCode, err := util.QRCodePNG(p.Url, qrCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	qrImage, err := png.Decode(bytes.NewReader(qrCode))
+	if err != nil {
+		return nil, err
+	}
+	qrRect := image.Rect((width-qrCodeSize)/2, height-qrCodeBottom-qrCodeSize, (width+qrCodeSize)/2, height-qrCodeBottom)
+	draw.Draw(canvas, qrRect, qrImage, qrImage.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeCover decode the cover after checking its dimensions
+func decodeCover(content []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxCoverPixels {
+		return nil, errCoverTooLarge
+	}
+
+	cover, _, err := image.Decode(bytes.NewReader(content))
+	return cover, err
+}
+
+// loadFont parse the PosterFont once it loads, so a font installed after
+// the start is picked up
+func loadFont() (*sfnt.Font, error) {
+	fontLock.Lock()
+	defer fontLock.Unlock()
+
+	if posterFont != nil {
+		return posterFont, nil
+	}
+
+	content, err := ioutil.ReadFile(settings.AppSetting.RuntimeRootPath + settings.AppSetting.FontSavePath + settings.AppSetting.PosterFont)
+	if err != nil {
+		return nil, err
+	}
+	f, err := opentype.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	posterFont = f
+	return posterFont, nil
+}
+
+// coverCrop return the centered part of bounds with the aspect ratio of dst
+func coverCrop(bounds, dst image.Rectangle) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if w*dst.Dy() > h*dst.Dx() {
+		cropped := h * dst.Dx() / dst.Dy()
+		x := bounds.Min.X + (w-cropped)/2
+		return image.Rect(x, bounds.Min.Y, x+cropped, bounds.Max.Y)
+	}
+
+	cropped := w * dst.Dy() / dst.Dx()
+	y := bounds.Min.Y + (h-cropped)/2
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+cropped)
+}
+
+// drawText draw text wrapped to the width between the margins from the top
+// y, in at most maxLines lines, and return the y below it
+func drawText(canvas draw.Image, f *sfnt.Font, text string, size float64, maxLines int, c color.Color, y int) (int, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return y, err
+	}
+	defer face.Close()
+
+	drawer := &font.Drawer{Dst: canvas, Src: image.NewUniform(c), Face: face}
+	lineHeight := int(size * lineSpacing)
+	for _, line := range wrap(drawer, text, fixed.I(width-2*margin), maxLines) {
+		y += lineHeight
+		drawer.Dot = fixed.P(margin, y-int(size*(lineSpacing-1)/2))
+		drawer.DrawString(line)
+	}
+
+	return y, nil
+}
+
+// wrap break text into lines no wider than maxWidth, between words when it
+// can and anywhere otherwise, as CJK text has no spaces. The last of
+// maxLines lines ends with an ellipsis when the text doesn't fit.
+func wrap(drawer *font.Drawer, text string, maxWidth fixed.Int26_6, maxLines int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		runes := []rune(strings.TrimSpace(paragraph))
+		for len(runes) > 0 {
+			if len(lines) == maxLines {
+				lines[maxLines-1] = ellipsize(drawer, lines[maxLines-1], maxWidth)
+				return lines
+			}
+
+			n := fitRunes(drawer, runes, maxWidth)
+			if n < len(runes) {
+				for i := n; i > 0; i-- {
+					if unicode.IsSpace(runes[i]) {
+						n = i
+						break
+					}
+				}
+			}
+			lines = append(lines, strings.TrimSpace(string(runes[:n])))
+			runes = []rune(strings.TrimLeftFunc(string(runes[n:]), unicode.IsSpace))
+		}
+	}
+
+	return lines
+}
+
+// fitRunes return how many of the runes fit in maxWidth, at least one
+func fitRunes(drawer *font.Drawer, runes []rune, maxWidth fixed.Int26_6) int {
+	for n := 1; n <= len(runes); n++ {
+		if drawer.MeasureString(string(runes[:n])) > maxWidth {
+			if n == 1 {
+				return 1
+			}
+			return n - 1
+		}
+	}
+
+	return len(runes)
+}
+
+// ellipsize shorten line until it fits in maxWidth with an ellipsis
+func ellipsize(drawer *font.Drawer, line string, maxWidth fixed.Int26_6) string {
+	runes := []rune(line)
+	for len(runes) > 0 && drawer.MeasureString(string(runes)+"…") > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+
+	return strings.TrimRightFunc(string(runes), unicode.IsSpace) + "…"
+}
+
+// save write the poster to fullPath through a temporary file, so a poster
+// being written is never served
+func save(content []byte, fullPath string) error {
+	dir := GetPosterFullPath()
+	if err := logging.IsNotExistMkDir(dir); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".poster-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fullPath)
+}
@@ -0,0 +1,61 @@
+package poster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// pngWithSize encode a 1x1 PNG whose header claims width x height
+func pngWithSize(t *testing.T, width, height uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Bytes()
+
+	// the IHDR chunk follows the 8 byte signature: length, type, data, crc
+	binary.BigEndian.PutUint32(content[16:], width)
+	binary.BigEndian.PutUint32(content[20:], height)
+	binary.BigEndian.PutUint32(content[29:], crc32.ChecksumIEEE(content[12:29]))
+
+	return content
+}
+
+func TestDecodeCover(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr error
+	}{
+		{name: "small", content: pngWithSize(t, 1, 1)},
+		{name: "decompression bomb", content: pngWithSize(t, 100000, 100000), wantErr: errCoverTooLarge},
+		{name: "too wide", content: pngWithSize(t, maxCoverPixels+1, 1), wantErr: errCoverTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCover(tt.content)
+			if err != tt.wantErr {
+				t.Errorf("decodeCover() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var bmpCover bytes.Buffer
+	if err := bmp.Encode(&bmpCover, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeCover(bmpCover.Bytes()); err != nil {
+		t.Errorf("decodeCover() of a BMP error = %v", err)
+	}
+
+	if _, err := decodeCover([]byte("not an image")); err == nil {
+		t.Error("decodeCover() of garbage succeeded")
+	}
+}
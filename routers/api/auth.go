@@ -2,34 +2,75 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/astaxie/beego/validation"
 	"github.com/gin-gonic/gin"
 
 	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
 	"github.com/miaozhang/webservice/service/auth_service"
+	"github.com/miaozhang/webservice/settings"
 	"github.com/miaozhang/webservice/util"
 )
 
-type auth struct {
-	Username string `valid:"Required; MaxSize(50)"`
-	Password string `valid:"Required; MaxSize(50)"`
+type LoginForm struct {
+	Username string `form:"username" json:"username" valid:"Required; MaxSize(50)"`
+	Password string `form:"password" json:"password" valid:"Required; MaxSize(50)"`
 }
 
+// @Summary Log in with a username and password
+// @Produce  json
+// @Param username body string true "Username"
+// @Param password body string true "Password"
+// @Success 200 {object} common.Response
+// @Failure 401 {object} common.Response
+// @Router /auth/login [post]
+func Login(c *gin.Context) {
+	var form LoginForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	login(c, form.Username, form.Password)
+}
+
+// GetAuth is the deprecated query string login, use Login instead
 func GetAuth(c *gin.Context) {
-	username := c.Query("username")
-	password := c.Query("password")
-	a := auth{Username: username, Password: password}
+	c.Header("Deprecation", "true")
+	if sunset := settings.AppSetting.LegacyAuthSunset; !sunset.IsZero() {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Link", `</auth/login>; rel="successor-version"`)
+
+	form := LoginForm{Username: c.Query("username"), Password: c.Query("password")}
 
 	valid := validation.Validation{}
-	ok, _ := valid.Valid(&a)
+	ok, _ := valid.Valid(&form)
 	if !ok {
 		common.MarkErrors(valid.Errors)
 		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
 		return
 	}
 
-	authService := auth_service.Auth{Username: username, Password: password}
+	login(c, form.Username, form.Password)
+}
+
+func login(c *gin.Context, username, password string) {
+	blockedFor, err := auth_service.LoginBlockedFor(username, c.ClientIP())
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_CHECK_TOKEN_FAIL, nil)
+		return
+	}
+	if blockedFor > 0 {
+		tooManyAttempts(c, blockedFor)
+		return
+	}
+
+	authService := auth_service.Auth{Username: username, Password: password, Client: client(c)}
 	isExist, err := authService.Check()
 	if err != nil {
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_CHECK_TOKEN_FAIL, nil)
@@ -37,17 +78,261 @@ func GetAuth(c *gin.Context) {
 	}
 
 	if !isExist {
+		blockedFor, err := auth_service.LoginFailed(username, c.ClientIP())
+		if err != nil {
+			logging.Error("api.login record failed login fail", username, err)
+		}
+		if blockedFor > 0 {
+			common.SetRetryAfter(c, blockedFor)
+		}
 		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH, nil)
 		return
 	}
 
-	token, err := util.GenerateToken(username, password)
+	if err := auth_service.LoginSucceeded(username); err != nil {
+		logging.Error("api.login reset failed logins fail", username, err)
+	}
+
+	completeLogin(c, &authService)
+}
+
+// completeLogin issue the token pair of the authenticated user, or the MFA
+// token to finish the login with at /auth/mfa when a second factor is enabled
+func completeLogin(c *gin.Context, authService *auth_service.Auth) {
+	if authService.MfaEnabled {
+		mfaToken, err := authService.IssueMfaToken()
+		if err != nil {
+			common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_TOKEN, nil)
+			return
+		}
+
+		common.OutputRes(c, http.StatusOK, common.ERROR_AUTH_MFA_REQUIRED, map[string]interface{}{
+			"mfa_token":  mfaToken,
+			"expires_in": int(settings.AppSetting.MfaTokenExpire.Seconds()),
+		})
+		return
+	}
+
+	tokens, err := authService.IssueTokens()
 	if err != nil {
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_TOKEN, nil)
 		return
 	}
 
-	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]string{
-		"token": token,
-	})
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, tokensData(tokens))
+}
+
+type MfaForm struct {
+	MfaToken string `form:"mfa_token" json:"mfa_token" valid:"Required;MaxSize(2048)"`
+	Code     string `form:"code" json:"code" valid:"Required;MaxSize(20)"`
+}
+
+// @Summary Complete a login with a TOTP or recovery code
+// @Produce  json
+// @Param mfa_token body string true "MfaToken"
+// @Param code body string true "Code"
+// @Success 200 {object} common.Response
+// @Failure 401 {object} common.Response
+// @Router /auth/mfa [post]
+func CompleteMfa(c *gin.Context) {
+	var form MfaForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	claims, err := util.ParseMfaToken(form.MfaToken)
+	if err != nil {
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH_MFA_TOKEN, nil)
+		return
+	}
+	blockedFor, err := auth_service.MfaBlockedFor(claims.UserID())
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_CHECK_TOKEN_FAIL, nil)
+		return
+	}
+	if blockedFor > 0 {
+		tooManyAttempts(c, blockedFor)
+		return
+	}
+
+	authID, tokens, err := auth_service.CompleteMfa(form.MfaToken, form.Code, client(c))
+	switch err {
+	case nil:
+	case auth_service.ErrMfaTokenInvalid:
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH_MFA_TOKEN, nil)
+		return
+	case auth_service.ErrTotpCodeInvalid:
+		blockedFor, err := auth_service.MfaFailed(authID)
+		if err != nil {
+			logging.Error("api.CompleteMfa record failed code fail", authID, err)
+		}
+		if blockedFor > 0 {
+			common.SetRetryAfter(c, blockedFor)
+		}
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH_MFA_CODE, nil)
+		return
+	default:
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_TOKEN, nil)
+		return
+	}
+
+	if err := auth_service.MfaSucceeded(authID); err != nil {
+		logging.Error("api.CompleteMfa reset failed codes fail", authID, err)
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, tokensData(tokens))
+}
+
+type RefreshForm struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token" valid:"Required;MaxSize(255)"`
+}
+
+// @Summary Exchange a refresh token for a new token pair
+// @Produce  json
+// @Param refresh_token body string true "RefreshToken"
+// @Success 200 {object} common.Response
+// @Failure 401 {object} common.Response
+// @Router /auth/refresh [post]
+func RefreshAuth(c *gin.Context) {
+	var form RefreshForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	tokens, err := auth_service.Refresh(form.RefreshToken)
+	switch err {
+	case nil:
+	case auth_service.ErrRefreshTokenInvalid:
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH_REFRESH_TOKEN, nil)
+		return
+	case auth_service.ErrRefreshTokenReused:
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_AUTH_REFRESH_TOKEN_REUSE, nil)
+		return
+	default:
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_TOKEN, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, tokensData(tokens))
+}
+
+type LogoutForm struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token" valid:"MaxSize(255)"`
+}
+
+// @Summary Revoke the current access token and, if given, its refresh token
+// @Produce  json
+// @Param refresh_token body string false "RefreshToken"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	var form LogoutForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	identity := common.GetIdentity(c)
+	if !identity.IsUser() {
+		common.OutputRes(c, http.StatusForbidden, common.FORBIDDEN, nil)
+		return
+	}
+
+	if err := auth_service.Logout(identity, form.RefreshToken); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_LOGOUT_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+type ForgotPasswordForm struct {
+	Username string `form:"username" json:"username" valid:"Required; MaxSize(50)"`
+}
+
+// @Summary Mail a password reset link to the user
+// @Produce  json
+// @Param username body string true "Username"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /auth/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var form ForgotPasswordForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	if err := auth_service.ForgotPassword(form.Username); err != nil {
+		logging.Error("api.ForgotPassword fail", err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_FORGOT_PASSWORD_FAIL, nil)
+		return
+	}
+
+	// the same answer whether or not the user exists
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+type ResetPasswordForm struct {
+	Token    string `form:"token" json:"token" valid:"Required; MaxSize(255)"`
+	Password string `form:"password" json:"password" valid:"Required; MinSize(8); MaxSize(50)"`
+}
+
+// @Summary Set a new password with the token of a reset link
+// @Produce  json
+// @Param token body string true "Token"
+// @Param password body string true "Password"
+// @Success 200 {object} common.Response
+// @Failure 400 {object} common.Response
+// @Router /auth/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var form ResetPasswordForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	switch err := auth_service.ResetPassword(form.Token, form.Password); err {
+	case nil:
+	case auth_service.ErrResetTokenInvalid:
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_RESET_TOKEN, nil)
+		return
+	default:
+		logging.Error("api.ResetPassword fail", err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_RESET_PASSWORD_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+func tooManyAttempts(c *gin.Context, blockedFor time.Duration) {
+	common.SetRetryAfter(c, blockedFor)
+	common.OutputRes(c, http.StatusTooManyRequests, common.ERROR_AUTH_TOO_MANY_ATTEMPTS, nil)
+}
+
+// client describe the device of the request for the session it starts
+func client(c *gin.Context) auth_service.Client {
+	return auth_service.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+func tokensData(tokens *auth_service.Tokens) map[string]interface{} {
+	return map[string]interface{}{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	}
 }
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/util"
+)
+
+// @Summary Public keys that verify the issued access tokens
+// @Produce  json
+// @Success 200 {object} util.JSONWebKey
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(util.JwksMaxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{
+		"keys": util.PublicJWKs(),
+	})
+}
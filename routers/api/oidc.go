@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/oidc"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// @Summary Redirect to an OpenID Connect provider to sign in
+// @Param provider path string true "Provider"
+// @Success 302
+// @Failure 404 {object} common.Response
+// @Router /auth/oidc/{provider}/login [get]
+func OidcLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, stateToken, err := auth_service.OidcLogin(provider)
+	switch err {
+	case nil:
+	case oidc.ErrUnknownProvider:
+		common.OutputRes(c, http.StatusNotFound, common.ERROR_OIDC_PROVIDER, nil)
+		return
+	default:
+		logging.Error("api.OidcLogin fail", provider, err)
+		common.OutputRes(c, http.StatusBadGateway, common.ERROR_OIDC_LOGIN_FAIL, nil)
+		return
+	}
+
+	c.SetCookie(oidcStateCookie, stateToken, int(auth_service.OidcStateExpire.Seconds()),
+		oidcCookiePath(provider), "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Finish an OpenID Connect sign in and issue a token pair, or an MFA token when a second factor is enabled
+// @Produce  json
+// @Param provider path string true "Provider"
+// @Param code query string true "Code"
+// @Param state query string true "State"
+// @Success 200 {object} common.Response
+// @Failure 401 {object} common.Response
+// @Router /auth/oidc/{provider}/callback [get]
+func OidcCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	stateToken, _ := c.Cookie(oidcStateCookie)
+	// the state is single use
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath(provider), "", c.Request.TLS != nil, true)
+
+	if errParam := c.Query("error"); errParam != "" {
+		logging.Warn("api.OidcCallback provider error", provider, errParam, c.Query("error_description"))
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_OIDC_LOGIN_FAIL, nil)
+		return
+	}
+	if c.Query("code") == "" || c.Query("state") == "" || stateToken == "" {
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_OIDC_STATE, nil)
+		return
+	}
+
+	authService, err := auth_service.OidcCallback(provider, stateToken, c.Query("state"), c.Query("code"), client(c))
+	switch err {
+	case nil:
+	case oidc.ErrUnknownProvider:
+		common.OutputRes(c, http.StatusNotFound, common.ERROR_OIDC_PROVIDER, nil)
+		return
+	case auth_service.ErrOidcStateInvalid:
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_OIDC_STATE, nil)
+		return
+	case auth_service.ErrOidcLoginFailed:
+		common.OutputRes(c, http.StatusUnauthorized, common.ERROR_OIDC_LOGIN_FAIL, nil)
+		return
+	default:
+		logging.Error("api.OidcCallback fail", provider, err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_AUTH_TOKEN, nil)
+		return
+	}
+
+	completeLogin(c, authService)
+}
+
+func oidcCookiePath(provider string) string {
+	return "/auth/oidc/" + provider
+}
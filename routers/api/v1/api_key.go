@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/astaxie/beego/validation"
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/service/api_key_service"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+// @Summary Get the API keys of the current user
+// @Produce  json
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/api-keys [get]
+func GetApiKeys(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	apiKeyService := api_key_service.ApiKey{AuthID: identity.ID}
+	keys, err := apiKeyService.GetAll()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_API_KEYS_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"lists": keys,
+		"total": len(keys),
+	})
+}
+
+type AddApiKeyForm struct {
+	Name      string   `form:"name" json:"name" valid:"Required;MaxSize(100)"`
+	Scopes    []string `form:"scopes" json:"scopes" valid:"Required"`
+	ExpiresIn int      `form:"expires_in" json:"expires_in" valid:"Required;Range(1,3650)"`
+}
+
+// @Summary Issue an API key for the current user
+// @Produce  json
+// @Param name body string true "Name"
+// @Param scopes body []string true "Scopes, e.g. tag:read"
+// @Param expires_in body int true "ExpiresIn in days"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/api-keys [post]
+func AddApiKey(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	var form AddApiKeyForm
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	// a key can never do more than its owner
+	for _, scope := range form.Scopes {
+		ok, err := auth_service.HasPermission(identity.Role, scope)
+		if err != nil {
+			common.OutputRes(c, http.StatusInternalServerError, common.ERROR_ADD_API_KEY_FAIL, nil)
+			return
+		}
+		if !ok {
+			common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+			return
+		}
+	}
+
+	apiKeyService := api_key_service.ApiKey{
+		AuthID:    identity.ID,
+		Name:      form.Name,
+		Scopes:    form.Scopes,
+		ExpiresIn: time.Duration(form.ExpiresIn) * 24 * time.Hour,
+	}
+	key, err := apiKeyService.Issue()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_ADD_API_KEY_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"key": key,
+	})
+}
+
+// @Summary Revoke an API key of the current user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/api-keys/{id} [delete]
+func DeleteApiKey(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	valid := validation.Validation{}
+	id := com.StrTo(c.Param("id")).MustInt()
+	valid.Min(id, 1, "id").Message("ID > 0")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	apiKeyService := api_key_service.ApiKey{ID: id, AuthID: identity.ID}
+	exists, err := apiKeyService.ExistByID()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_DELETE_API_KEY_FAIL, nil)
+		return
+	}
+	if !exists {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_API_KEY, nil)
+		return
+	}
+
+	if err := apiKeyService.Revoke(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_DELETE_API_KEY_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// sessionIdentity return the caller when they logged in as a user; API keys
+// and services can't manage the account
+func sessionIdentity(c *gin.Context) (*common.Identity, bool) {
+	identity := common.GetIdentity(c)
+	if identity == nil || !identity.IsUser() {
+		common.OutputRes(c, http.StatusForbidden, common.FORBIDDEN, nil)
+		return nil, false
+	}
+
+	return identity, true
+}
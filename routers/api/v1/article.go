@@ -2,6 +2,11 @@ package v1
 
 import (
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Unknwon/com"
 	"github.com/astaxie/beego/validation"
@@ -32,6 +37,9 @@ func GetArticle(c *gin.Context) {
 	}
 
 	articleService := article_service.Article{ID: id}
+	if !viewArticles(c, &articleService) {
+		return
+	}
 	exists, err := articleService.ExistByID()
 	if err != nil {
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_ARTICLE_FAIL, nil)
@@ -47,6 +55,51 @@ func GetArticle(c *gin.Context) {
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_FAIL, nil)
 		return
 	}
+	if article.ID == 0 {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, article)
+}
+
+// @Summary Get a single article by its slug, former slugs redirect to the current one. Without a token, at /articles/{slug}, only published articles are found.
+// @Produce  json
+// @Param slug path string true "Slug"
+// @Success 200 {object} common.Response
+// @Success 301 {string} string "Location of the current slug"
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/by-slug/{slug} [get]
+// @Router /articles/{slug} [get]
+func GetArticleBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+	valid := validation.Validation{}
+	valid.Required(slug, "slug")
+	valid.MaxSize(slug, 100, "slug")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	articleService := article_service.Article{Slug: slug}
+	if !viewArticles(c, &articleService) {
+		return
+	}
+	article, current, err := articleService.GetBySlug()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_FAIL, nil)
+		return
+	}
+	if current != "" {
+		redirectToSlug(c, current)
+		return
+	}
+	if article == nil {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE, nil)
+		return
+	}
 
 	common.OutputRes(c, http.StatusOK, common.SUCCESS, article)
 }
@@ -54,7 +107,9 @@ func GetArticle(c *gin.Context) {
 // @Summary Get multiple articles
 // @Produce  json
 // @Param tag_id body int false "TagID"
-// @Param state body int false "State"
+// @Param tag_ids query []int false "TagIDs, repeated or comma separated"
+// @Param tag_match query string false "any (default) or all of tag_ids"
+// @Param state body int false "State: 0 draft, 1 published, 2 in_review, 3 scheduled, 4 archived"
 // @Param created_by body int false "CreatedBy"
 // @Success 200 {object} common.Response
 // @Failure 500 {object} common.Response
@@ -64,7 +119,7 @@ func GetArticles(c *gin.Context) {
 	state := -1
 	if arg := c.PostForm("state"); arg != "" {
 		state = com.StrTo(arg).MustInt()
-		valid.Range(state, 0, 1, "state")
+		valid.Range(state, 0, 4, "state")
 	}
 
 	tagId := -1
@@ -73,6 +128,15 @@ func GetArticles(c *gin.Context) {
 		valid.Min(tagId, 1, "tag_id")
 	}
 
+	tagIDs, ok := parseIDs(c.QueryArray("tag_ids"))
+	if !ok {
+		valid.SetError("tag_ids", "invalid tag id")
+	}
+	tagMatch := c.DefaultQuery("tag_match", "any")
+	if tagMatch != "any" && tagMatch != "all" {
+		valid.SetError("tag_match", "must be any or all")
+	}
+
 	if valid.HasErrors() {
 		common.MarkErrors(valid.Errors)
 		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
@@ -81,10 +145,15 @@ func GetArticles(c *gin.Context) {
 
 	articleService := article_service.Article{
 		TagID:    tagId,
+		TagIDs:   tagIDs,
+		AllTags:  tagMatch == "all",
 		State:    state,
 		PageNum:  util.GetPage(c),
 		PageSize: settings.AppSetting.PageSize,
 	}
+	if !viewArticles(c, &articleService) {
+		return
+	}
 
 	total, err := articleService.Count()
 	if err != nil {
@@ -105,23 +174,68 @@ func GetArticles(c *gin.Context) {
 	common.OutputRes(c, http.StatusOK, common.SUCCESS, data)
 }
 
+// @Summary Search articles by relevance over title, desc and content
+// @Produce  json
+// @Param q query string true "Query"
+// @Param page query int false "Page"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/search [get]
+func SearchArticles(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	valid := validation.Validation{}
+	valid.Required(q, "q")
+	valid.MaxSize(q, 100, "q")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	articleService := article_service.Article{
+		Query:    q,
+		PageNum:  util.GetPage(c),
+		PageSize: settings.AppSetting.PageSize,
+	}
+	if !viewArticles(c, &articleService) {
+		return
+	}
+
+	results, total, err := articleService.Search()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_SEARCH_ARTICLES_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"lists": results,
+		"total": total,
+	})
+}
+
 type AddArticleForm struct {
-	TagID     int    `form:"tag_id" valid:"Required;Min(1)"`
-	Title     string `form:"title" valid:"Required;MaxSize(100)"`
-	Desc      string `form:"desc" valid:"Required;MaxSize(255)"`
-	Content   string `form:"content" valid:"Required;MaxSize(65535)"`
-	CreatedBy string `form:"created_by" valid:"Required;MaxSize(100)"`
-	State     int    `form:"state" valid:"Range(0,1)"`
+	TagID   int      `form:"tag_id" valid:"Min(0)"`
+	TagIDs  []string `form:"tag_ids"`
+	Title   string   `form:"title" valid:"Required;MaxSize(100)"`
+	Desc    string   `form:"desc" valid:"Required;MaxSize(255)"`
+	Content string   `form:"content" valid:"Required;MaxSize(65535)"`
+	State   int      `form:"state" valid:"Range(0,4)"`
+
+	PublishAt     int    `form:"publish_at" valid:"Min(0)"`
+	CoverImageUrl string `form:"cover_image_url"`
 }
 
 // @Summary Add article
 // @Produce  json
-// @Param tag_id body int true "TagID"
+// @Param tag_id body int false "TagID, the primary tag"
+// @Param tag_ids body []int false "TagIDs, repeated or comma separated, tag_id or tag_ids is required"
 // @Param title body string true "Title"
 // @Param desc body string true "Desc"
 // @Param content body string true "Content"
-// @Param created_by body string true "CreatedBy"
-// @Param state body int true "State"
+// @Param state body int false "State: 0 draft (default), 1 published, 2 in_review, 3 scheduled"
+// @Param publish_at body int false "Unix time to publish a scheduled article at"
+// @Param cover_image_url body string false "CoverImageUrl, e.g. the image_url of /upload/image"
 // @Success 200 {object} common.Response
 // @Failure 500 {object} common.Response
 // @Router /api/v1/articles [post]
@@ -134,25 +248,32 @@ func AddArticle(c *gin.Context) {
 		return
 	}
 
-	tagService := tag_service.Tag{ID: form.TagID}
-	exists, err := tagService.ExistByID()
-	if err != nil {
-		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EXIST_TAG_FAIL, nil)
+	tagID, tagIDs, ok := articleTags(c, form.TagID, form.TagIDs)
+	if !ok {
 		return
 	}
 
-	if !exists {
-		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_TAG, nil)
+	if !validCoverImageUrl(c, form.CoverImageUrl) {
+		return
+	}
+
+	identity := common.GetIdentity(c)
+	if err := article_service.CheckNewState(identity, form.State, form.PublishAt); err != nil {
+		articleStateError(c, err)
 		return
 	}
 
 	articleService := article_service.Article{
-		TagID:     form.TagID,
-		Title:     form.Title,
-		Desc:      form.Desc,
-		Content:   form.Content,
-		State:     form.State,
-		CreatedBy: form.CreatedBy,
+		TagID:         tagID,
+		TagIDs:        tagIDs,
+		Title:         form.Title,
+		Desc:          form.Desc,
+		Content:       form.Content,
+		State:         form.State,
+		CoverImageUrl: &form.CoverImageUrl,
+		PublishAt:     form.PublishAt,
+		CreatedBy:     identity.Username,
+		CreatedByID:   identity.ID,
 	}
 	if err := articleService.Add(); err != nil {
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_ADD_ARTICLE_FAIL, nil)
@@ -163,29 +284,37 @@ func AddArticle(c *gin.Context) {
 }
 
 type EditArticleForm struct {
-	ID         int    `form:"id" valid:"Required;Min(1)"`
-	TagID      int    `form:"tag_id" valid:"Required;Min(1)"`
-	Title      string `form:"title" valid:"Required;MaxSize(100)"`
-	Desc       string `form:"desc" valid:"Required;MaxSize(255)"`
-	Content    string `form:"content" valid:"Required;MaxSize(65535)"`
-	ModifiedBy string `form:"modified_by" valid:"Required;MaxSize(100)"`
-	State      int    `form:"state" valid:"Range(0,1)"`
+	ID      int      `form:"id" valid:"Required;Min(1)"`
+	TagID   int      `form:"tag_id" valid:"Min(0)"`
+	TagIDs  []string `form:"tag_ids"`
+	Title   string   `form:"title" valid:"Required;MaxSize(100)"`
+	Desc    string   `form:"desc" valid:"Required;MaxSize(255)"`
+	Content string   `form:"content" valid:"Required;MaxSize(65535)"`
+	// State is -1 when the request leaves it alone
+	State int `form:"state" valid:"Range(-1,4)"`
+
+	PublishAt int `form:"publish_at" valid:"Min(0)"`
+	// CoverImageUrl is nil when the request keeps the current cover, empty
+	// to remove it
+	CoverImageUrl *string `form:"cover_image_url"`
 }
 
 // @Summary Update article
 // @Produce  json
 // @Param id path int true "ID"
-// @Param tag_id body string false "TagID"
+// @Param tag_id body int false "TagID, the primary tag"
+// @Param tag_ids body []int false "TagIDs, repeated or comma separated, tag_id or tag_ids is required"
 // @Param title body string false "Title"
 // @Param desc body string false "Desc"
 // @Param content body string false "Content"
-// @Param modified_by body string true "ModifiedBy"
-// @Param state body int false "State"
+// @Param state body int false "State to move the article to along the edit, see /articles/{id}/state"
+// @Param publish_at body int false "Unix time to publish a scheduled article at"
+// @Param cover_image_url body string false "CoverImageUrl, empty to remove the cover"
 // @Success 200 {object} common.Response
 // @Failure 500 {object} common.Response
 // @Router /api/v1/articles/{id} [put]
 func EditArticle(c *gin.Context) {
-	form := EditArticleForm{ID: com.StrTo(c.Param("id")).MustInt()}
+	form := EditArticleForm{ID: com.StrTo(c.Param("id")).MustInt(), State: -1}
 
 	httpCode, errCode := common.BindAndValid(c, &form)
 	if errCode != common.SUCCESS {
@@ -193,14 +322,19 @@ func EditArticle(c *gin.Context) {
 		return
 	}
 
+	if form.CoverImageUrl != nil && !validCoverImageUrl(c, *form.CoverImageUrl) {
+		return
+	}
+
+	identity := common.GetIdentity(c)
 	articleService := article_service.Article{
-		ID:         form.ID,
-		TagID:      form.TagID,
-		Title:      form.Title,
-		Desc:       form.Desc,
-		Content:    form.Content,
-		ModifiedBy: form.ModifiedBy,
-		State:      form.State,
+		ID:            form.ID,
+		Title:         form.Title,
+		Desc:          form.Desc,
+		Content:       form.Content,
+		CoverImageUrl: form.CoverImageUrl,
+		ModifiedBy:    identity.Username,
+		ModifiedByID:  identity.ID,
 	}
 	exists, err := articleService.ExistByID()
 	if err != nil {
@@ -211,21 +345,25 @@ func EditArticle(c *gin.Context) {
 		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE, nil)
 		return
 	}
-
-	tagService := tag_service.Tag{ID: form.TagID}
-	exists, err = tagService.ExistByID()
-	if err != nil {
-		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EXIST_TAG_FAIL, nil)
+	if !canModifyArticle(c, &articleService) {
 		return
 	}
 
-	if !exists {
-		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_TAG, nil)
+	tagID, tagIDs, ok := articleTags(c, form.TagID, form.TagIDs)
+	if !ok {
 		return
 	}
-
-	err = articleService.Edit()
-	if err != nil {
+	articleService.TagID = tagID
+	articleService.TagIDs = tagIDs
+
+	err = articleService.Edit(identity, form.State, form.PublishAt)
+	switch err {
+	case nil:
+	case article_service.ErrInvalidTransition, article_service.ErrInvalidPublishAt,
+		article_service.ErrPublishForbidden, article_service.ErrStateChanged:
+		articleStateError(c, err)
+		return
+	default:
 		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EDIT_ARTICLE_FAIL, nil)
 		return
 	}
@@ -260,6 +398,9 @@ func DeleteArticle(c *gin.Context) {
 		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE, nil)
 		return
 	}
+	if !canModifyArticle(c, &articleService) {
+		return
+	}
 
 	err = articleService.Delete()
 	if err != nil {
@@ -269,3 +410,122 @@ func DeleteArticle(c *gin.Context) {
 
 	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
 }
+
+// viewArticles restrict the reads of the service to the articles the caller
+// may see, writing the error response and returning false when it can't
+func viewArticles(c *gin.Context, articleService *article_service.Article) bool {
+	if err := articleService.SetViewer(common.GetIdentity(c)); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_ARTICLE_FAIL, nil)
+		return false
+	}
+
+	return true
+}
+
+// canModifyArticle check the caller wrote the article or may manage every
+// article, writing the error response and returning false otherwise
+func canModifyArticle(c *gin.Context, articleService *article_service.Article) bool {
+	ok, err := articleService.CanModify(common.GetIdentity(c))
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_ARTICLE_FAIL, nil)
+		return false
+	}
+	if !ok {
+		common.OutputRes(c, http.StatusForbidden, common.FORBIDDEN, nil)
+		return false
+	}
+
+	return true
+}
+
+// maxArticleTags bound the number of tags of an article
+const maxArticleTags = 10
+
+// articleTags merge the v1 tag_id with tag_ids, given like the tag_ids of
+// GetArticles, and check every tag exists. tag_id is the primary tag and
+// defaults to the first of tag_ids. The error response is written when
+// false is returned.
+func articleTags(c *gin.Context, tagID int, values []string) (int, []int, bool) {
+	tagIDs, ok := parseIDs(values)
+	if !ok {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return 0, nil, false
+	}
+	if tagID > 0 {
+		tagIDs = append([]int{tagID}, tagIDs...)
+	}
+
+	ids := make([]int, 0, len(tagIDs))
+	seen := make(map[int]bool, len(tagIDs))
+	for _, id := range tagIDs {
+		if seen[id] {
+			continue
+		}
+		if id < 1 || len(ids) == maxArticleTags {
+			common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+			return 0, nil, false
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return 0, nil, false
+	}
+
+	for _, id := range ids {
+		tagService := tag_service.Tag{ID: id}
+		exists, err := tagService.ExistByID()
+		if err != nil {
+			common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EXIST_TAG_FAIL, nil)
+			return 0, nil, false
+		}
+		if !exists {
+			common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_TAG, nil)
+			return 0, nil, false
+		}
+	}
+
+	return ids[0], ids, true
+}
+
+// parseIDs read ids given as repeated values and/or comma separated lists
+func parseIDs(values []string) ([]int, bool) {
+	var ids []int
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil || id < 1 {
+				return nil, false
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, true
+}
+
+// redirectToSlug permanently redirect a by-slug request to the current slug
+func redirectToSlug(c *gin.Context, slug string) {
+	c.Redirect(http.StatusMovedPermanently, path.Dir(c.Request.URL.Path)+"/"+url.PathEscape(slug))
+}
+
+// validCoverImageUrl check the cover is empty or an absolute http(s) url
+// fitting its column, writing the error response otherwise
+func validCoverImageUrl(c *gin.Context, coverImageUrl string) bool {
+	if coverImageUrl == "" {
+		return true
+	}
+
+	u, err := url.Parse(coverImageUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		utf8.RuneCountInString(coverImageUrl) > 255 {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/poster"
+)
+
+// @Summary Generate the share poster of an article, with a QR code to it
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/poster [post]
+func GenerateArticlePoster(c *gin.Context) {
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+
+	posterName, err := articleService.GeneratePoster()
+	if err != nil {
+		logging.Warn(err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GEN_ARTICLE_POSTER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]string{
+		"poster_url":      poster.GetPosterFullUrl(posterName),
+		"poster_save_url": poster.GetPosterPath() + posterName,
+	})
+}
@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/Unknwon/com"
+	"github.com/astaxie/beego/validation"
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/service/article_service"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// @Summary Get the revisions of an article, latest first
+// @Produce  json
+// @Param id path int true "ID"
+// @Param page query int false "Page"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/revisions [get]
+func GetArticleRevisions(c *gin.Context) {
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+	articleService.PageNum = util.GetPage(c)
+	articleService.PageSize = settings.AppSetting.PageSize
+
+	total, err := articleService.CountRevisions()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_REVISIONS_FAIL, nil)
+		return
+	}
+
+	revisions, err := articleService.GetRevisions()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_REVISIONS_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"lists": revisions,
+		"total": total,
+	})
+}
+
+// @Summary Get a single revision of an article
+// @Produce  json
+// @Param id path int true "ID"
+// @Param rev path int true "Revision"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/revisions/{rev} [get]
+func GetArticleRevision(c *gin.Context) {
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := articleRevision(c, articleService, com.StrTo(c.Param("rev")).MustInt())
+	if !ok {
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, revision)
+}
+
+// @Summary Get the unified diff between two revisions of an article
+// @Produce  json
+// @Param id path int true "ID"
+// @Param from query int true "Revision to diff from"
+// @Param to query int true "Revision to diff to"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/diff [get]
+func DiffArticleRevisions(c *gin.Context) {
+	from := com.StrTo(c.Query("from")).MustInt()
+	to := com.StrTo(c.Query("to")).MustInt()
+	valid := validation.Validation{}
+	valid.Min(from, 1, "from").Message("from > 0")
+	valid.Min(to, 1, "to").Message("to > 0")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+	fromRevision, ok := articleRevision(c, articleService, from)
+	if !ok {
+		return
+	}
+	toRevision, ok := articleRevision(c, articleService, to)
+	if !ok {
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, article_service.DiffRevisions(fromRevision, toRevision))
+}
+
+// @Summary Restore a revision of an article as a new edit
+// @Produce  json
+// @Param id path int true "ID"
+// @Param rev path int true "Revision"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/revisions/{rev}/restore [post]
+func RestoreArticleRevision(c *gin.Context) {
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+	if !canModifyArticle(c, articleService) {
+		return
+	}
+
+	revision, ok := articleRevision(c, articleService, com.StrTo(c.Param("rev")).MustInt())
+	if !ok {
+		return
+	}
+
+	identity := common.GetIdentity(c)
+	articleService.ModifiedBy = identity.Username
+	articleService.ModifiedByID = identity.ID
+	if err := articleService.Restore(identity, revision); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_RESTORE_ARTICLE_REVISION_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// existingArticle check the article of the path exists and the caller may
+// see it, writing the error response and returning false otherwise
+func existingArticle(c *gin.Context) (*article_service.Article, bool) {
+	id := com.StrTo(c.Param("id")).MustInt()
+	valid := validation.Validation{}
+	valid.Min(id, 1, "id").Message("ID > 0")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return nil, false
+	}
+
+	articleService := &article_service.Article{ID: id}
+	if !viewArticles(c, articleService) {
+		return nil, false
+	}
+	exists, err := articleService.ExistByID()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_ARTICLE_FAIL, nil)
+		return nil, false
+	}
+	if !exists {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE, nil)
+		return nil, false
+	}
+
+	return articleService, true
+}
+
+// articleRevision load the revision of the article, writing the error
+// response and returning false when it can't
+func articleRevision(c *gin.Context, articleService *article_service.Article, rev int) (*models.ArticleRevision, bool) {
+	if rev < 1 {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return nil, false
+	}
+
+	revision, err := articleService.GetRevision(rev)
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_REVISION_FAIL, nil)
+		return nil, false
+	}
+	if revision.ID == 0 {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_ARTICLE_REVISION, nil)
+		return nil, false
+	}
+
+	return revision, true
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/service/article_service"
+)
+
+type ArticleStateForm struct {
+	State     string `form:"state" valid:"Required"`
+	PublishAt int    `form:"publish_at" valid:"Min(0)"`
+}
+
+// @Summary Move an article through the editorial workflow
+// @Produce  json
+// @Param id path int true "ID"
+// @Param state body string true "draft, in_review, scheduled, published or archived"
+// @Param publish_at body int false "Unix time to publish at, required to schedule"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/state [post]
+func TransitionArticle(c *gin.Context) {
+	var form ArticleStateForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+	state, ok := article_service.ParseState(form.State)
+	if !ok {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+	if !canModifyArticle(c, articleService) {
+		return
+	}
+
+	if err := articleService.Transition(common.GetIdentity(c), state, form.PublishAt); err != nil {
+		articleStateError(c, err)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// @Summary Get the state transitions of an article, latest first
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/articles/{id}/transitions [get]
+func GetArticleTransitions(c *gin.Context) {
+	articleService, ok := existingArticle(c)
+	if !ok {
+		return
+	}
+
+	transitions, err := articleService.GetTransitions()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_ARTICLE_TRANSITIONS_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, transitions)
+}
+
+// articleStateError write the response for an error of a state change
+func articleStateError(c *gin.Context, err error) {
+	switch err {
+	case article_service.ErrInvalidTransition:
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_ARTICLE_TRANSITION, nil)
+	case article_service.ErrInvalidPublishAt:
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_ARTICLE_PUBLISH_AT, nil)
+	case article_service.ErrPublishForbidden:
+		common.OutputRes(c, http.StatusForbidden, common.FORBIDDEN, nil)
+	case article_service.ErrStateChanged:
+		common.OutputRes(c, http.StatusConflict, common.ERROR_ARTICLE_TRANSITION, nil)
+	default:
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_TRANSITION_ARTICLE_FAIL, nil)
+	}
+}
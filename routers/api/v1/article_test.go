@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+)
+
+func TestGetArticlesTagFilter(t *testing.T) {
+	useTestDB(t)
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/api/v1/articles", GetArticles)
+
+	for _, name := range []string{"go", "web", "db"} {
+		if err := models.AddTag(name, 1, "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, article := range []struct {
+		title  string
+		tagIDs []int
+	}{
+		{"go and web", []int{1, 2}},
+		{"go", []int{1}},
+		{"db", []int{3}},
+	} {
+		_, err := models.AddArticle(map[string]interface{}{
+			"tag_id":        article.tagIDs[0],
+			"tag_ids":       article.tagIDs,
+			"title":         article.title,
+			"desc":          "",
+			"content":       "",
+			"created_by":    "alice",
+			"created_by_id": 1,
+			"state":         models.ARTICLE_STATE_PUBLISHED,
+			"publish_at":    0,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantTotal int
+	}{
+		{name: "no filter", query: "", wantCode: common.SUCCESS, wantTotal: 3},
+		{name: "any of comma separated tags", query: "?tag_ids=1,3", wantCode: common.SUCCESS, wantTotal: 3},
+		{name: "repeated tag_ids", query: "?tag_ids=2&tag_ids=3", wantCode: common.SUCCESS, wantTotal: 2},
+		{name: "all tags", query: "?tag_ids=1,2&tag_match=all", wantCode: common.SUCCESS, wantTotal: 1},
+		{name: "single tag", query: "?tag_ids=1", wantCode: common.SUCCESS, wantTotal: 2},
+		{name: "invalid tag id", query: "?tag_ids=1,x", wantCode: common.INVALID_PARAMS},
+		{name: "invalid tag_match", query: "?tag_ids=1&tag_match=some", wantCode: common.INVALID_PARAMS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, r, "GET", "/api/v1/articles"+tt.query, "")
+			if res.Code != tt.wantCode {
+				t.Fatalf("GetArticles() code = %d, want %d", res.Code, tt.wantCode)
+			}
+			if tt.wantCode != common.SUCCESS {
+				return
+			}
+			total := res.Data.(map[string]interface{})["total"].(float64)
+			if int(total) != tt.wantTotal {
+				t.Errorf("GetArticles() total = %v, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
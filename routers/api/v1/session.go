@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/Unknwon/com"
+	"github.com/astaxie/beego/validation"
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+// @Summary Get the devices the current user is signed in on
+// @Produce  json
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/me/sessions [get]
+func GetSessions(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := auth_service.GetSessions(identity.ID, identity.SessionID)
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_SESSIONS_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"lists": sessions,
+		"total": len(sessions),
+	})
+}
+
+// @Summary Sign the current user out of one device
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/me/sessions/{id} [delete]
+func DeleteSession(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	valid := validation.Validation{}
+	id := com.StrTo(c.Param("id")).MustInt()
+	valid.Min(id, 1, "id").Message("ID > 0")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	switch err := auth_service.EndSession(identity.ID, id); err {
+	case nil:
+	case auth_service.ErrSessionNotFound:
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_SESSION, nil)
+		return
+	default:
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_DELETE_SESSION_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
@@ -53,17 +53,52 @@ func GetTags(c *gin.Context) {
 	})
 }
 
+// @Summary Get a single tag by its slug, former slugs redirect to the current one
+// @Produce  json
+// @Param slug path string true "Slug"
+// @Success 200 {object} common.Response
+// @Success 301 {string} string "Location of the current slug"
+// @Failure 500 {object} common.Response
+// @Router /api/v1/tags/by-slug/{slug} [get]
+func GetTagBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+	valid := validation.Validation{}
+	valid.Required(slug, "slug")
+	valid.MaxSize(slug, 100, "slug")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	tagService := tag_service.Tag{Slug: slug}
+	tag, current, err := tagService.GetBySlug()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_TAGS_FAIL, nil)
+		return
+	}
+	if current != "" {
+		redirectToSlug(c, current)
+		return
+	}
+	if tag == nil {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_TAG, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, tag)
+}
+
 type AddTagForm struct {
-	Name      string `form:"name" valid:"Required;MaxSize(100)"`
-	CreatedBy string `form:"created_by" valid:"Required;MaxSize(100)"`
-	State     int    `form:"state" valid:"Range(0,1)"`
+	Name  string `form:"name" valid:"Required;MaxSize(100)"`
+	State int    `form:"state" valid:"Range(0,1)"`
 }
 
 // @Summary Add new tag
 // @Produce json
 // @Param name query string true "Name"
 // @Param state query int false "State"
-// @Param created_by query int false "CreatedBy"
 // @Success 200 {object} common.Response
 // @Failure 500 {object} common.Response
 // @Router /api/v1/tags [post]
@@ -78,7 +113,7 @@ func AddTag(c *gin.Context) {
 
 	tagService := tag_service.Tag{
 		Name:      form.Name,
-		CreatedBy: form.CreatedBy,
+		CreatedBy: common.GetIdentity(c).Username,
 		State:     form.State,
 	}
 
@@ -103,17 +138,15 @@ func AddTag(c *gin.Context) {
 }
 
 type EditTagForm struct {
-	ID         int    `form:"id" valid:"Required;Min(1)"`
-	Name       string `form:"name" valid:"Required;MaxSize(100)"`
-	ModifiedBy string `form:"modified_by" valid:"Required;MaxSize(100)"`
-	State      int    `form:"state" valid:"Range(0,1)"`
+	ID    int    `form:"id" valid:"Required;Min(1)"`
+	Name  string `form:"name" valid:"Required;MaxSize(100)"`
+	State int    `form:"state" valid:"Range(0,1)"`
 }
 
 // @Summary Edit tag
 // @Produce json
 // @Param id path int true "ID"
 // @Param state query int false "State"
-// @Param modified_by query string true "ModifiedBy"
 // @Success 200 {object} app.Response
 // @Failure 500 {object} app.Response
 // @Router /api/v1/tags/{id} [put]
@@ -129,7 +162,7 @@ func EditTag(c *gin.Context) {
 	tagService := tag_service.Tag{
 		ID:         form.ID,
 		Name:       form.Name,
-		ModifiedBy: form.ModifiedBy,
+		ModifiedBy: common.GetIdentity(c).Username,
 		State:      form.State,
 	}
 
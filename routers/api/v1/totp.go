@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+// @Summary Start TOTP enrollment for the current user
+// @Produce  json
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/me/totp [post]
+func EnrollTotp(c *gin.Context) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := auth_service.EnrollTotp(identity.ID)
+	if err == auth_service.ErrTotpAlreadyEnabled {
+		common.OutputRes(c, http.StatusOK, common.ERROR_TOTP_ALREADY_ENABLED, nil)
+		return
+	}
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_TOTP_ENROLL_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"secret":  enrollment.Secret,
+		"uri":     enrollment.URI,
+		"qr_code": "data:image/png;base64," + base64.StdEncoding.EncodeToString(enrollment.QRCode),
+	})
+}
+
+type TotpCodeForm struct {
+	Code string `form:"code" json:"code" valid:"Required;MaxSize(20)"`
+}
+
+// @Summary Confirm TOTP enrollment with a first code and get the recovery codes
+// @Produce  json
+// @Param code body string true "Code"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/me/totp/confirm [post]
+func ConfirmTotp(c *gin.Context) {
+	identity, form, ok := totpCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	codes, err := auth_service.ConfirmTotp(identity.ID, form.Code)
+	if !totpResult(c, identity.ID, err) {
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// @Summary Turn TOTP off for the current user
+// @Produce  json
+// @Param code body string true "TOTP or recovery code"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/me/totp [delete]
+func DisableTotp(c *gin.Context) {
+	identity, form, ok := totpCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	err := auth_service.DisableTotp(identity.ID, form.Code)
+	if !totpResult(c, identity.ID, err) {
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// totpCodeRequest bind the code and refuse it while the user is locked out
+// after too many wrong codes
+func totpCodeRequest(c *gin.Context) (*common.Identity, *TotpCodeForm, bool) {
+	identity, ok := sessionIdentity(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var form TotpCodeForm
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return nil, nil, false
+	}
+
+	blockedFor, err := auth_service.MfaBlockedFor(identity.ID)
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_TOTP_ENROLL_FAIL, nil)
+		return nil, nil, false
+	}
+	if blockedFor > 0 {
+		common.SetRetryAfter(c, blockedFor)
+		common.OutputRes(c, http.StatusTooManyRequests, common.ERROR_AUTH_TOO_MANY_ATTEMPTS, nil)
+		return nil, nil, false
+	}
+
+	return identity, &form, true
+}
+
+// totpResult write the response for a failed TOTP operation and count wrong codes
+func totpResult(c *gin.Context, authID int, err error) bool {
+	switch err {
+	case nil:
+		if err := auth_service.MfaSucceeded(authID); err != nil {
+			logging.Error("v1.totpResult reset failed codes fail", authID, err)
+		}
+		return true
+	case auth_service.ErrTotpAlreadyEnabled:
+		common.OutputRes(c, http.StatusOK, common.ERROR_TOTP_ALREADY_ENABLED, nil)
+	case auth_service.ErrTotpNotEnrolled:
+		common.OutputRes(c, http.StatusOK, common.ERROR_TOTP_NOT_ENROLLED, nil)
+	case auth_service.ErrTotpCodeInvalid:
+		if _, err := auth_service.MfaFailed(authID); err != nil {
+			logging.Error("v1.totpResult record failed code fail", authID, err)
+		}
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_AUTH_MFA_CODE, nil)
+	default:
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_TOTP_ENROLL_FAIL, nil)
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/upload"
+)
+
+// uploadOverhead is the room left in the request body for the multipart
+// headers around the image
+const uploadOverhead = 64 * 1024
+
+// @Summary Upload an image, e.g. an article cover
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param image formData file true "Image"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/upload/image [post]
+func UploadImage(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(settings.AppSetting.ImageMaxSize+uploadOverhead))
+	file, image, err := c.Request.FormFile("image")
+	if err != nil {
+		logging.Warn(err)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+	defer file.Close()
+
+	if !upload.CheckImageExt(image.Filename) || !upload.CheckImageSize(file) {
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_UPLOAD_CHECK_IMAGE_FORMAT, nil)
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		logging.Warn(err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_UPLOAD_CHECK_IMAGE_FAIL, nil)
+		return
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		logging.Warn(err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_UPLOAD_CHECK_IMAGE_FAIL, nil)
+		return
+	}
+	// the extension is the client's word, the content must agree with it
+	if !upload.CheckImageType(content, image.Filename) {
+		common.OutputRes(c, http.StatusBadRequest, common.ERROR_UPLOAD_CHECK_IMAGE_FORMAT, nil)
+		return
+	}
+
+	imageName := upload.GetImageName(content, image.Filename)
+	if err := upload.SaveImage(content, imageName); err != nil {
+		logging.Warn(err)
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_UPLOAD_SAVE_IMAGE_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]string{
+		"image_url":      upload.GetImageFullUrl(imageName),
+		"image_save_url": upload.GetImagePath() + imageName,
+	})
+}
@@ -0,0 +1,330 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/Unknwon/com"
+	"github.com/astaxie/beego/validation"
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/service/user_service"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// @Summary Get multiple users
+// @Produce  json
+// @Param username query string false "Username"
+// @Param role query string false "Role"
+// @Param state query int false "State"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users [get]
+func GetUsers(c *gin.Context) {
+	state := -1
+	if arg := c.Query("state"); arg != "" {
+		state = com.StrTo(arg).MustInt()
+	}
+
+	userService := user_service.User{
+		Username: c.Query("username"),
+		Role:     c.Query("role"),
+		State:    state,
+		PageNum:  util.GetPage(c),
+		PageSize: settings.AppSetting.PageSize,
+	}
+
+	users, err := userService.GetAll()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_USERS_FAIL, nil)
+		return
+	}
+
+	count, err := userService.Count()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_COUNT_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, map[string]interface{}{
+		"lists": users,
+		"total": count,
+	})
+}
+
+// @Summary Get a single user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id} [get]
+func GetUser(c *gin.Context) {
+	userService, ok := existingUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := userService.Get()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_GET_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, user)
+}
+
+type AddUserForm struct {
+	Username string `form:"username" json:"username" valid:"Required;MaxSize(50)"`
+	Password string `form:"password" json:"password" valid:"Required;MinSize(8);MaxSize(50)"`
+	Email    string `form:"email" json:"email" valid:"MaxSize(100)"`
+	Role     string `form:"role" json:"role" valid:"Required;MaxSize(20)"`
+}
+
+// @Summary Add user
+// @Produce  json
+// @Param username body string true "Username"
+// @Param password body string true "Password"
+// @Param email body string false "Email"
+// @Param role body string true "Role"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users [post]
+func AddUser(c *gin.Context) {
+	var form AddUserForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+	if !models.IsRole(form.Role) || !validEmail(form.Email) {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	userService := user_service.User{
+		Username: form.Username,
+		Password: form.Password,
+		Email:    form.Email,
+		Role:     form.Role,
+	}
+
+	exists, err := userService.ExistByUsername()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_USER_FAIL, nil)
+		return
+	}
+	if exists {
+		common.OutputRes(c, http.StatusOK, common.ERROR_EXIST_USER, nil)
+		return
+	}
+
+	if err := userService.Add(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_ADD_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+type EditUserForm struct {
+	// ID comes from the path only
+	ID       int    `form:"-" json:"-" valid:"Required;Min(1)"`
+	Username string `form:"username" json:"username" valid:"Required;MaxSize(50)"`
+	Email    string `form:"email" json:"email" valid:"MaxSize(100)"`
+	Role     string `form:"role" json:"role" valid:"Required;MaxSize(20)"`
+	// State is -1 when the request leaves it alone
+	State int `form:"state" json:"state" valid:"Range(-1,1)"`
+}
+
+// @Summary Update user
+// @Produce  json
+// @Param id path int true "ID"
+// @Param username body string true "Username"
+// @Param email body string false "Email"
+// @Param role body string true "Role"
+// @Param state body int false "State, unchanged when omitted"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id} [put]
+func EditUser(c *gin.Context) {
+	form := EditUserForm{ID: com.StrTo(c.Param("id")).MustInt(), State: -1}
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+	if !models.IsRole(form.Role) || !validEmail(form.Email) {
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return
+	}
+
+	userService := user_service.User{
+		ID:       form.ID,
+		Username: form.Username,
+		Email:    form.Email,
+		Role:     form.Role,
+		State:    form.State,
+	}
+
+	exists, err := userService.ExistByID()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_USER_FAIL, nil)
+		return
+	}
+	if !exists {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_USER, nil)
+		return
+	}
+
+	taken, err := userService.UsernameTaken()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_USER_FAIL, nil)
+		return
+	}
+	if taken {
+		common.OutputRes(c, http.StatusOK, common.ERROR_EXIST_USER, nil)
+		return
+	}
+
+	if err := userService.Edit(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EDIT_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// @Summary Disable user and revoke their tokens
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id}/disable [post]
+func DisableUser(c *gin.Context) {
+	userService, ok := existingUser(c)
+	if !ok {
+		return
+	}
+
+	if err := userService.Disable(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_EDIT_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+type ResetUserPasswordForm struct {
+	Password string `form:"password" json:"password" valid:"Required;MinSize(8);MaxSize(50)"`
+}
+
+// @Summary Reset the password of a user
+// @Produce  json
+// @Param id path int true "ID"
+// @Param password body string true "Password"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id}/password [put]
+func ResetUserPassword(c *gin.Context) {
+	var form ResetUserPasswordForm
+
+	httpCode, errCode := common.BindAndValid(c, &form)
+	if errCode != common.SUCCESS {
+		common.OutputRes(c, httpCode, errCode, nil)
+		return
+	}
+
+	userService, ok := existingUser(c)
+	if !ok {
+		return
+	}
+
+	userService.Password = form.Password
+	if err := userService.ResetPassword(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_RESET_USER_PASSWORD_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// @Summary Revoke every access and refresh token and API key of a user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id}/revoke-tokens [post]
+func RevokeUserTokens(c *gin.Context) {
+	userService, ok := existingUser(c)
+	if !ok {
+		return
+	}
+
+	if err := userService.RevokeTokens(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_REVOKE_USER_TOKENS_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// @Summary Delete user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} common.Response
+// @Failure 500 {object} common.Response
+// @Router /api/v1/users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	userService, ok := existingUser(c)
+	if !ok {
+		return
+	}
+
+	if err := userService.Delete(); err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_DELETE_USER_FAIL, nil)
+		return
+	}
+
+	common.OutputRes(c, http.StatusOK, common.SUCCESS, nil)
+}
+
+// existingUser validate the :id parameter and check the user exists, writing
+// the error response and returning false otherwise
+func existingUser(c *gin.Context) (*user_service.User, bool) {
+	id := com.StrTo(c.Param("id")).MustInt()
+	valid := validation.Validation{}
+	valid.Min(id, 1, "id").Message("ID > 0")
+
+	if valid.HasErrors() {
+		common.MarkErrors(valid.Errors)
+		common.OutputRes(c, http.StatusBadRequest, common.INVALID_PARAMS, nil)
+		return nil, false
+	}
+
+	userService := &user_service.User{ID: id}
+	exists, err := userService.ExistByID()
+	if err != nil {
+		common.OutputRes(c, http.StatusInternalServerError, common.ERROR_CHECK_EXIST_USER_FAIL, nil)
+		return nil, false
+	}
+	if !exists {
+		common.OutputRes(c, http.StatusOK, common.ERROR_NOT_EXIST_USER, nil)
+		return nil, false
+	}
+
+	return userService, true
+}
+
+// validEmail accept an empty email, the field is optional
+func validEmail(email string) bool {
+	if email == "" {
+		return true
+	}
+
+	valid := validation.Validation{}
+	return valid.Email(email, "email").Ok
+}
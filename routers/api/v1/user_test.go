@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+)
+
+func TestEditUser(t *testing.T) {
+	useTestDB(t, &models.Auth{}, &models.RefreshToken{}, &models.Session{}, &models.ApiKey{})
+	r := gin.New()
+	r.PUT("/api/v1/users/:id", EditUser)
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := models.AddAuth(username, "", "", models.ROLE_READER); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice, _ := models.GetAuthByUsername("alice")
+	bob, _ := models.GetAuthByUsername("bob")
+
+	tests := []struct {
+		name      string
+		body      string
+		wantState int
+	}{
+		{
+			name:      "disable",
+			body:      `{"username": "alice", "role": "author", "state": 0}`,
+			wantState: models.AUTH_STATE_DISABLED,
+		},
+		{
+			name:      "state left out keeps the account disabled",
+			body:      `{"username": "alice", "role": "editor"}`,
+			wantState: models.AUTH_STATE_DISABLED,
+		},
+		{
+			name:      "id in the body is ignored",
+			body:      fmt.Sprintf(`{"id": %d, "username": "alice", "role": "reader"}`, bob.ID),
+			wantState: models.AUTH_STATE_DISABLED,
+		},
+		{
+			name:      "enable",
+			body:      `{"username": "alice", "role": "reader", "state": 1}`,
+			wantState: models.AUTH_STATE_ACTIVE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, r, "PUT", fmt.Sprintf("/api/v1/users/%d", alice.ID), tt.body)
+			if res.Code != common.SUCCESS {
+				t.Fatalf("EditUser() code = %d, want %d", res.Code, common.SUCCESS)
+			}
+
+			auth, err := models.GetAuth(alice.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth.State != tt.wantState {
+				t.Errorf("EditUser() state = %d, want %d", auth.State, tt.wantState)
+			}
+		})
+	}
+
+	other, err := models.GetAuth(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Role != models.ROLE_READER || other.Username != "bob" || other.State != models.AUTH_STATE_ACTIVE {
+		t.Errorf("EditUser() changed the user of the body id: %+v", other)
+	}
+}
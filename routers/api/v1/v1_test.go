@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/internal/testdb"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// useTestDB run the models on an empty in-memory database with the tables
+// of values, and reset the stores of util
+func useTestDB(t *testing.T, values ...interface{}) *gorm.DB {
+	conn := testdb.Open(t, models.UseDB, values...)
+
+	gin.SetMode(gin.TestMode)
+	settings.AppSetting.JwtSecret = "v1-test-secret"
+	settings.AppSetting.JwtIssuer = "webservice"
+	settings.AppSetting.JwtAudience = "webservice"
+	settings.AppSetting.JwtExpire = time.Hour
+	settings.AppSetting.TokenDenylist = "memory"
+	settings.AppSetting.PageSize = 10
+	util.Setup()
+
+	return conn
+}
+
+// serve run the request through r and decode the response
+func serve(t *testing.T, r http.Handler, method, target, body string) common.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var res common.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("%s %s: %v in %q", method, target, err, w.Body.String())
+	}
+	return res
+}
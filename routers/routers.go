@@ -1,15 +1,21 @@
 package routers
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 
 	_ "github.com/miaozhang/webservice/docs"
 	"github.com/miaozhang/webservice/middleware/jwt"
+	"github.com/miaozhang/webservice/middleware/rbac"
+	"github.com/miaozhang/webservice/poster"
 	"github.com/miaozhang/webservice/routers/api"
 	v1 "github.com/miaozhang/webservice/routers/api/v1"
 	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/upload"
 )
 
 func InitRouter() *gin.Engine {
@@ -18,22 +24,95 @@ func InitRouter() *gin.Engine {
 	r.Use(gin.Recovery())
 	gin.SetMode(settings.ServerSetting.RunMode)
 
-	r.GET("/auth", api.GetAuth)
+	r.POST("/auth/login", api.Login)
+	if settings.AppSetting.LegacyAuth {
+		r.GET("/auth", api.GetAuth)
+	}
+	r.POST("/auth/mfa", api.CompleteMfa)
+	r.POST("/auth/refresh", api.RefreshAuth)
+	r.POST("/auth/logout", jwt.JWT(), api.Logout)
+	r.POST("/auth/password/forgot", api.ForgotPassword)
+	r.POST("/auth/password/reset", api.ResetPassword)
+	r.GET("/auth/oidc/:provider/login", api.OidcLogin)
+	r.GET("/auth/oidc/:provider/callback", api.OidcCallback)
+	r.GET("/.well-known/jwks.json", api.GetJWKS)
 	r.GET("/swagger/*ang", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// the public permalink of the published articles, where the posters link
+	r.GET("/articles/:slug", v1.GetArticleBySlug)
+	// images and posters are served where GetImageFullUrl and GetPosterFullUrl
+	// point, without directory listings
+	r.Static("/"+strings.TrimSuffix(upload.GetImagePath(), "/"), upload.GetImageFullPath())
+	r.Static("/"+strings.TrimSuffix(poster.GetPosterPath(), "/"), poster.GetPosterFullPath())
 
 	apiv1 := r.Group("api/v1")
 	apiv1.Use(jwt.JWT())
 	{
-		apiv1.GET("/tags", v1.GetTags)
-		apiv1.POST("/tags", v1.AddTag)
-		apiv1.PUT("/tags/:id", v1.EditTag)
-		apiv1.DELETE("/tags/:id", v1.DeleteTag)
-
-		apiv1.GET("/articles", v1.GetArticles)
-		apiv1.GET("/articles/:id", v1.GetArticle)
-		apiv1.POST("/articles", v1.AddArticle)
-		apiv1.PUT("/articles/:id", v1.EditArticle)
-		apiv1.DELETE("/articles/:id", v1.DeleteArticle)
+		apiv1.GET("/tags", rbac.RequirePermission("tag:read"), v1.GetTags)
+		apiv1.GET("/tags/by-slug/:slug", rbac.RequirePermission("tag:read"), v1.GetTagBySlug)
+		apiv1.POST("/tags", rbac.RequirePermission("tag:write"), v1.AddTag)
+		apiv1.PUT("/tags/:id", rbac.RequirePermission("tag:write"), v1.EditTag)
+		apiv1.DELETE("/tags/:id", rbac.RequirePermission("tag:delete"), v1.DeleteTag)
+
+		apiv1.GET("/articles", rbac.RequirePermission("article:read"), v1.GetArticles)
+		// gin 1.6 can't register /articles/search next to /articles/:id
+		apiv1.GET("/articles/:id", rbac.RequirePermission("article:read"), func(c *gin.Context) {
+			if c.Param("id") == "search" {
+				v1.SearchArticles(c)
+				return
+			}
+			v1.GetArticle(c)
+		})
+		apiv1.POST("/articles", rbac.RequirePermission("article:write"), v1.AddArticle)
+		apiv1.PUT("/articles/:id", rbac.RequirePermission("article:write"), v1.EditArticle)
+		apiv1.DELETE("/articles/:id", rbac.RequirePermission("article:delete"), v1.DeleteArticle)
+		// nor /articles/by-slug/:slug next to /articles/:id/revisions and
+		// the other GET /articles/:id/... routes
+		apiv1.GET("/articles/:id/:sub", rbac.RequirePermission("article:read"), func(c *gin.Context) {
+			switch {
+			case c.Param("id") == "by-slug":
+				c.Params = append(c.Params, gin.Param{Key: "slug", Value: c.Param("sub")})
+				v1.GetArticleBySlug(c)
+			case c.Param("sub") == "revisions":
+				v1.GetArticleRevisions(c)
+			case c.Param("sub") == "diff":
+				v1.DiffArticleRevisions(c)
+			case c.Param("sub") == "transitions":
+				v1.GetArticleTransitions(c)
+			default:
+				c.AbortWithStatus(http.StatusNotFound)
+			}
+		})
+		apiv1.GET("/articles/:id/:sub/:rev", rbac.RequirePermission("article:read"), func(c *gin.Context) {
+			if c.Param("sub") != "revisions" {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			v1.GetArticleRevision(c)
+		})
+		apiv1.POST("/articles/:id/revisions/:rev/restore", rbac.RequirePermission("article:write"), v1.RestoreArticleRevision)
+		apiv1.POST("/articles/:id/state", rbac.RequirePermission("article:write"), v1.TransitionArticle)
+		apiv1.POST("/articles/:id/poster", rbac.RequirePermission("article:read"), v1.GenerateArticlePoster)
+
+		apiv1.POST("/upload/image", rbac.RequirePermission("article:write"), v1.UploadImage)
+
+		apiv1.POST("/me/totp", v1.EnrollTotp)
+		apiv1.POST("/me/totp/confirm", v1.ConfirmTotp)
+		apiv1.DELETE("/me/totp", v1.DisableTotp)
+		apiv1.GET("/me/sessions", v1.GetSessions)
+		apiv1.DELETE("/me/sessions/:id", v1.DeleteSession)
+
+		apiv1.GET("/api-keys", v1.GetApiKeys)
+		apiv1.POST("/api-keys", v1.AddApiKey)
+		apiv1.DELETE("/api-keys/:id", v1.DeleteApiKey)
+
+		apiv1.GET("/users", rbac.RequirePermission("user:manage"), v1.GetUsers)
+		apiv1.GET("/users/:id", rbac.RequirePermission("user:manage"), v1.GetUser)
+		apiv1.POST("/users", rbac.RequirePermission("user:manage"), v1.AddUser)
+		apiv1.PUT("/users/:id", rbac.RequirePermission("user:manage"), v1.EditUser)
+		apiv1.POST("/users/:id/disable", rbac.RequirePermission("user:manage"), v1.DisableUser)
+		apiv1.PUT("/users/:id/password", rbac.RequirePermission("user:manage"), v1.ResetUserPassword)
+		apiv1.POST("/users/:id/revoke-tokens", rbac.RequirePermission("user:manage"), v1.RevokeUserTokens)
+		apiv1.DELETE("/users/:id", rbac.RequirePermission("user:manage"), v1.DeleteUser)
 	}
 
 	return r
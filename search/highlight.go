@@ -0,0 +1,106 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightOpen  = "<em>"
+	highlightClose = "</em>"
+	ellipsis       = "…"
+)
+
+// Highlight return an HTML snippet of at most size runes of text around the
+// first matched term, the matches wrapped in <em>. The text is escaped so
+// the snippet is safe to render. An empty string means no term matched.
+func Highlight(text string, terms []string, size int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// mark every rune covered by a match
+	matched := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if !hasPrefix(lower[i:], t) || !atWordBoundary(lower, i, i+len(t)) {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				matched[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if size > 0 && len(runes) > size {
+		start = first - size/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + size
+		if end > len(runes) {
+			end = len(runes)
+			start = end - size
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(ellipsis)
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && matched[j] == matched[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if matched[i] {
+			b.WriteString(highlightOpen + segment + highlightClose)
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString(ellipsis)
+	}
+
+	return b.String()
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// atWordBoundary report whether s[start:end] is a whole word, CJK terms match anywhere
+func atWordBoundary(s []rune, start, end int) bool {
+	if isCJK(s[start]) {
+		return true
+	}
+
+	return (start == 0 || !isWordRune(s[start-1])) && (end == len(s) || !isWordRune(s[end]))
+}
+
+func isWordRune(r rune) bool {
+	return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
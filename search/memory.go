@@ -0,0 +1,187 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/miaozhang/webservice/models"
+)
+
+// field weights, a term in the title counts more than one in the content
+const (
+	titleWeight   = 3.0
+	descWeight    = 2.0
+	contentWeight = 1.0
+
+	// bm25K1 saturate the weight of repeated terms
+	bm25K1 = 1.2
+)
+
+type posting struct {
+	title   int
+	desc    int
+	content int
+}
+
+func (p *posting) weight() float64 {
+	return titleWeight*float64(p.title) + descWeight*float64(p.desc) + contentWeight*float64(p.content)
+}
+
+// MemoryIndex is an embedded inverted index ranking with BM25 over the
+// weighted term frequencies of the fields. It needs no database support,
+// which suits SQLite and test setups, but lives in the memory of one process.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]*posting
+	docs     map[int][]string
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings: make(map[string]map[int]*posting),
+		docs:     make(map[int][]string),
+	}
+}
+
+// Load index every article in the database
+func (m *MemoryIndex) Load() error {
+	articles, err := models.GetAllArticles()
+	if err != nil {
+		return err
+	}
+
+	for _, article := range articles {
+		err := m.Index(&Document{ID: article.ID, Title: article.Title, Desc: article.Desc, Content: article.Content})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryIndex) Index(doc *Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(doc.ID)
+
+	postings := make(map[string]*posting)
+	add := func(text string, count func(p *posting)) {
+		for _, term := range Terms(text) {
+			p, ok := postings[term]
+			if !ok {
+				p = &posting{}
+				postings[term] = p
+			}
+			count(p)
+		}
+	}
+	add(doc.Title, func(p *posting) { p.title++ })
+	add(doc.Desc, func(p *posting) { p.desc++ })
+	add(doc.Content, func(p *posting) { p.content++ })
+
+	terms := make([]string, 0, len(postings))
+	for term, p := range postings {
+		if m.postings[term] == nil {
+			m.postings[term] = make(map[int]*posting)
+		}
+		m.postings[term][doc.ID] = p
+		terms = append(terms, term)
+	}
+	m.docs[doc.ID] = terms
+
+	return nil
+}
+
+func (m *MemoryIndex) Remove(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(id)
+	return nil
+}
+
+func (m *MemoryIndex) remove(id int) {
+	for _, term := range m.docs[id] {
+		delete(m.postings[term], id)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	delete(m.docs, id)
+}
+
+// Search match the documents containing any term of the query. The index
+// doesn't know the state of the articles, the database filters the hits.
+func (m *MemoryIndex) Search(query string, visible *models.ArticleVisibility, offset, limit int) ([]Hit, int, error) {
+	scores := m.score(query)
+	if visible != nil {
+		ids := make([]int, 0, len(scores))
+		for id := range scores {
+			ids = append(ids, id)
+		}
+		visibleIDs, err := models.GetVisibleArticleIDs(ids, visible)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		visibleScores := make(map[int]float64, len(visibleIDs))
+		for _, id := range visibleIDs {
+			visibleScores[id] = scores[id]
+		}
+		scores = visibleScores
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+
+	total := len(hits)
+	if offset >= total {
+		return []Hit{}, total, nil
+	}
+	end := offset + limit
+	if limit <= 0 || end > total {
+		end = total
+	}
+
+	return hits[offset:end], total, nil
+}
+
+// score return the BM25 score of every document matching the query
+func (m *MemoryIndex) score(query string) map[int]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+	n := float64(len(m.docs))
+	for _, term := range Terms(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := m.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, p := range docs {
+			w := p.weight()
+			scores[id] += idf * w * (bm25K1 + 1) / (w + bm25K1)
+		}
+	}
+
+	return scores
+}
@@ -0,0 +1,32 @@
+package search
+
+import (
+	"github.com/miaozhang/webservice/models"
+)
+
+// MySQLIndex rank with the FULLTEXT index that models.Migrate creates over
+// the title, desc and content columns. MySQL keeps it up to date, so Index
+// and Remove have nothing to do.
+type MySQLIndex struct{}
+
+func (m *MySQLIndex) Index(doc *Document) error {
+	return nil
+}
+
+func (m *MySQLIndex) Remove(id int) error {
+	return nil
+}
+
+func (m *MySQLIndex) Search(query string, visible *models.ArticleVisibility, offset, limit int) ([]Hit, int, error) {
+	scores, total, err := models.SearchArticles(query, visible, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for _, s := range scores {
+		hits = append(hits, Hit{ID: s.ID, Score: s.Score})
+	}
+
+	return hits, total, nil
+}
@@ -0,0 +1,117 @@
+package search
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// Document is the searchable part of an article
+type Document struct {
+	ID      int
+	Title   string
+	Desc    string
+	Content string
+}
+
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index rank articles against a free text query
+type Index interface {
+	// Index add or replace the document
+	Index(doc *Document) error
+	Remove(id int) error
+	// Search return the hits among the visible articles of the requested
+	// page, best first, and the total number of hits
+	Search(query string, visible *models.ArticleVisibility, offset, limit int) ([]Hit, int, error)
+}
+
+var index Index = NewMemoryIndex()
+
+// Setup select the index configured by SearchIndex. The memory index is
+// filled with every article at startup.
+func Setup() {
+	switch settings.AppSetting.SearchIndex {
+	case "mysql":
+		index = &MySQLIndex{}
+	case "", "memory":
+		memory := NewMemoryIndex()
+		if err := memory.Load(); err != nil {
+			log.Fatalf("search.Setup, fail to load the articles: %v", err)
+		}
+		index = memory
+	default:
+		log.Fatalf("search.Setup, unknown search index %q", settings.AppSetting.SearchIndex)
+	}
+}
+
+func IndexArticle(article *models.Article) error {
+	return index.Index(&Document{
+		ID:      article.ID,
+		Title:   article.Title,
+		Desc:    article.Desc,
+		Content: article.Content,
+	})
+}
+
+func RemoveArticle(id int) error {
+	return index.Remove(id)
+}
+
+func Search(query string, visible *models.ArticleVisibility, offset, limit int) ([]Hit, int, error) {
+	return index.Search(query, visible, offset, limit)
+}
+
+// Terms split text into lower case search terms. Han, Hiragana, Katakana and
+// Hangul runs have no spaces between words, they are cut into overlapping
+// bigrams like the MySQL ngram parser does.
+func Terms(text string) []string {
+	var terms []string
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			terms = append(terms, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				terms = append(terms, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return terms
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"go1.14 release", []string{"go1", "14", "release"}},
+		{"中文搜索", []string{"中文", "文搜", "搜索"}},
+		{"Go语言", []string{"go", "语言"}},
+		{"字 single", []string{"字", "single"}},
+		{"  ", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryIndexRanking(t *testing.T) {
+	m := NewMemoryIndex()
+	for _, doc := range []*Document{
+		{ID: 1, Title: "Cooking pasta", Content: "boil water, add golang noodles"},
+		{ID: 2, Title: "Golang tips", Desc: "small golang tricks", Content: "golang golang"},
+		{ID: 3, Title: "Weekend", Desc: "golang meetup notes"},
+		{ID: 4, Title: "Gardening", Content: "tomatoes"},
+		{ID: 5, Title: "Golang", Content: "a post to remove"},
+	} {
+		if err := m.Index(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Remove(5); err != nil {
+		t.Fatal(err)
+	}
+	// reindexing replaces the terms of the document
+	if err := m.Index(&Document{ID: 4, Title: "Gardening", Content: "tomatoes and golang"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		offset, limit int
+		wantIDs       []int
+		wantTotal     int
+	}{
+		// the title outweighs the desc, which outweighs the content;
+		// equal scores go newest first
+		{name: "ranked by field weight", query: "golang", limit: 10, wantIDs: []int{2, 3, 4, 1}, wantTotal: 4},
+		{name: "first page", query: "golang", limit: 2, wantIDs: []int{2, 3}, wantTotal: 4},
+		{name: "second page", query: "golang", offset: 2, limit: 2, wantIDs: []int{4, 1}, wantTotal: 4},
+		{name: "past the last page", query: "golang", offset: 4, limit: 2, wantIDs: []int{}, wantTotal: 4},
+		{name: "rare term ranks first", query: "golang pasta", limit: 10, wantIDs: []int{1, 2, 3, 4}, wantTotal: 4},
+		{name: "case insensitive", query: "TOMATOES", limit: 10, wantIDs: []int{4}, wantTotal: 1},
+		{name: "removed document", query: "remove", limit: 10, wantIDs: []int{}, wantTotal: 0},
+		{name: "no match", query: "kubernetes", limit: 10, wantIDs: []int{}, wantTotal: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, total, err := m.Search(tt.query, nil, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, 0, len(hits))
+			for _, hit := range hits {
+				ids = append(ids, hit.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || total != tt.wantTotal {
+				t.Errorf("Search(%q) = %v of %d, want %v of %d", tt.query, ids, total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		size  int
+		want  string
+	}{
+		{"whole text", "Learn Go fast", []string{"go"}, 0, "Learn <em>Go</em> fast"},
+		{"whole words only", "Going to Go", []string{"go"}, 0, "Going to <em>Go</em>"},
+		{"several terms", "go and rust", []string{"go", "rust"}, 0, "<em>go</em> and <em>rust</em>"},
+		{"escaped", "<b>go</b> & co", []string{"go"}, 0, "&lt;b&gt;<em>go</em>&lt;/b&gt; &amp; co"},
+		{"no match", "nothing here", []string{"go"}, 0, ""},
+		{"snippet around the match", "aaaa bbbb go cccc dddd", []string{"go"}, 8, "…b <em>go</em> ccc…"},
+		{"snippet at the start", "go aaaa bbbb cccc", []string{"go"}, 8, "<em>go</em> aaaa …"},
+		{"snippet at the end", "aaaa bbbb cccc go", []string{"go"}, 8, "… cccc <em>go</em>"},
+		{"CJK match inside a run", "学习中文搜索", []string{"中文"}, 0, "学习<em>中文</em>搜索"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.terms, tt.size); got != tt.want {
+				t.Errorf("Highlight() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
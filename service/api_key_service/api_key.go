@@ -0,0 +1,108 @@
+package api_key_service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/util"
+)
+
+// KeyPrefix marks a bearer credential as an API key rather than a JWT
+const KeyPrefix = "gbk_"
+
+// touchInterval limit how often last_used_on is written for a busy key
+const touchInterval = time.Minute
+
+var ErrApiKeyInvalid = errors.New("api key is invalid, revoked or expired")
+
+type ApiKey struct {
+	ID        int
+	AuthID    int
+	Name      string
+	Scopes    []string
+	ExpiresIn time.Duration
+}
+
+// IsApiKey report whether a bearer credential looks like an API key
+func IsApiKey(credential string) bool {
+	return strings.HasPrefix(credential, KeyPrefix)
+}
+
+// Issue create the key and return its plaintext, which is never stored
+func (k *ApiKey) Issue() (string, error) {
+	prefix, err := util.RandomToken(4)
+	if err != nil {
+		return "", err
+	}
+	secret, err := util.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	key := KeyPrefix + prefix + "_" + secret
+	expiresOn := time.Now().Add(k.ExpiresIn).Unix()
+	err = models.AddApiKey(k.AuthID, k.Name, prefix, util.HashToken(key), strings.Join(k.Scopes, ","), int(expiresOn))
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+func (k *ApiKey) GetAll() ([]*models.ApiKey, error) {
+	return models.GetApiKeys(k.AuthID)
+}
+
+// ExistByID check the key exists, is not revoked and belongs to k.AuthID
+func (k *ApiKey) ExistByID() (bool, error) {
+	return models.ExistApiKey(k.ID, k.AuthID)
+}
+
+func (k *ApiKey) Revoke() error {
+	return models.RevokeApiKey(k.ID)
+}
+
+// Authenticate resolve an API key to the identity of its owner, limited to the key's scopes
+func Authenticate(key string) (*common.Identity, error) {
+	apiKey, err := models.GetApiKeyByHash(util.HashToken(key))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if apiKey.ID == 0 || apiKey.RevokedOn != 0 || int64(apiKey.ExpiresOn) < now {
+		return nil, ErrApiKeyInvalid
+	}
+
+	auth, err := models.GetAuth(apiKey.AuthID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.ID == 0 || auth.State != models.AUTH_STATE_ACTIVE {
+		return nil, ErrApiKeyInvalid
+	}
+
+	if now-int64(apiKey.LastUsedOn) >= int64(touchInterval.Seconds()) {
+		if err := models.TouchApiKey(apiKey.ID, int(now)); err != nil {
+			logging.Warn("api_key_service.Authenticate update last used fail", apiKey.ID, err)
+		}
+	}
+
+	scopes := []string{}
+	if apiKey.Scopes != "" {
+		scopes = strings.Split(apiKey.Scopes, ",")
+	}
+
+	return &common.Identity{
+		ID:        auth.ID,
+		Username:  auth.Username,
+		Role:      auth.Role,
+		ApiKeyID:  apiKey.ID,
+		Scopes:    scopes,
+		ExpiresAt: int64(apiKey.ExpiresOn),
+	}, nil
+}
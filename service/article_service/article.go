@@ -1,51 +1,161 @@
 package article_service
 
 import (
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/markdown"
 	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/search"
+	"github.com/miaozhang/webservice/service/auth_service"
 )
 
+// snippetSize is the length in runes of the highlighted snippets
+const snippetSize = 160
+
 type Article struct {
 	ID         int
 	TagID      int
+	TagIDs     []int
 	Title      string
+	Slug       string
 	Desc       string
 	Content    string
 	State      int
+	PublishAt  int
 	CreatedBy  string
 	ModifiedBy string
 
+	CreatedByID  int
+	ModifiedByID int
+	// RestoredFrom is the revision an edit restores, 0 for a plain edit
+	RestoredFrom int
+	// CoverImageUrl is nil when an edit keeps the current cover
+	CoverImageUrl *string
+
+	Query string
+	// AllTags only matches articles having every tag of TagIDs instead of any
+	AllTags bool
+	// Visibility restrict the reads to the articles the caller may see, see SetViewer
+	Visibility *models.ArticleVisibility
+
 	PageNum  int
 	PageSize int
 }
 
+// SearchResult is an article matching a search with its relevance and the
+// highlighted snippets of the fields that matched
+type SearchResult struct {
+	*models.Article
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Add create the article in a.State, which the caller checked with
+// CheckNewState. publish_at is only kept for scheduled articles.
 func (a *Article) Add() error {
+	switch a.State {
+	case models.ARTICLE_STATE_PUBLISHED:
+		a.PublishAt = int(time.Now().Unix())
+	case models.ARTICLE_STATE_SCHEDULED:
+	default:
+		a.PublishAt = 0
+	}
+
 	article := map[string]interface{}{
 		"tag_id":     a.TagID,
+		"tag_ids":    a.TagIDs,
 		"title":      a.Title,
 		"desc":       a.Desc,
 		"content":    a.Content,
 		"created_by": a.CreatedBy,
 		"state":      a.State,
+		"publish_at": a.PublishAt,
+
+		"created_by_id": a.CreatedByID,
+	}
+	if a.CoverImageUrl != nil {
+		article["cover_image_url"] = *a.CoverImageUrl
 	}
 
-	if err := models.AddArticle(article); err != nil {
+	id, err := models.AddArticle(article)
+	if err != nil {
 		return err
 	}
 
+	a.ID = id
+	a.reindex()
 	return nil
 }
 
-func (a *Article) Edit() error {
-	return models.EditArticle(a.ID, map[string]interface{}{
+// Edit update the content and tags of the article on behalf of identity and,
+// unless to is -1, move it to state to in the same transaction. An edit of a
+// published or scheduled article by a caller who can't publish sends it back
+// to review, the new content isn't live before someone approves it.
+func (a *Article) Edit(identity *common.Identity, to, publishAt int) error {
+	current, err := models.GetArticle(a.ID)
+	if err != nil {
+		return err
+	}
+	to, publishAt, err = editState(identity, current, to, publishAt)
+	if err != nil {
+		return err
+	}
+
+	article := map[string]interface{}{
 		"tag_id":      a.TagID,
+		"tag_ids":     a.TagIDs,
 		"title":       a.Title,
 		"desc":        a.Desc,
 		"content":     a.Content,
-		"state":       a.State,
 		"modified_by": a.ModifiedBy,
-	})
+
+		"modified_by_id": a.ModifiedByID,
+		"restored_from":  a.RestoredFrom,
+	}
+	if a.CoverImageUrl != nil {
+		article["cover_image_url"] = *a.CoverImageUrl
+	}
+	if to != -1 {
+		article["state"] = to
+		article["from_state"] = current.State
+		article["publish_at"] = publishAt
+	}
+
+	edited, err := models.EditArticle(a.ID, article)
+	if err != nil {
+		return err
+	}
+	if !edited && to != -1 {
+		return ErrStateChanged
+	}
+
+	a.reindex()
+	return nil
+}
+
+// SetViewer restrict the reads to the articles identity may see: every
+// article with article:manage, else the published ones and its own
+func (a *Article) SetViewer(identity *common.Identity) error {
+	manage, err := canManage(identity)
+	if err != nil {
+		return err
+	}
+
+	a.Visibility = nil
+	if !manage {
+		a.Visibility = &models.ArticleVisibility{}
+		if identity != nil {
+			a.Visibility.AuthorID = identity.ID
+		}
+	}
+
+	return nil
 }
 
+// Get return the article with its content rendered to HTML, an article with
+// ID 0 when it isn't visible
 func (a *Article) Get() (*models.Article, error) {
 	var article *models.Article
 
@@ -53,14 +163,51 @@ func (a *Article) Get() (*models.Article, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !a.Visibility.Allows(article) {
+		return &models.Article{}, nil
+	}
+
+	article.ContentHTML, err = markdown.Render(article.Content)
+	if err != nil {
+		return nil, err
+	}
 
 	return article, nil
 }
 
+// GetBySlug return the article having a.Slug. When the slug is a former one
+// of an article, that article's current slug is returned instead to
+// redirect to. Both are empty when no article ever had the slug.
+func (a *Article) GetBySlug() (*models.Article, string, error) {
+	id, err := models.GetArticleIDBySlug(a.Slug)
+	if err != nil {
+		return nil, "", err
+	}
+	if id > 0 {
+		a.ID = id
+		article, err := a.Get()
+		if err != nil || article.ID == 0 {
+			return nil, "", err
+		}
+		return article, "", nil
+	}
+
+	id, err = models.GetSlugRedirect(models.SLUG_KIND_ARTICLE, a.Slug)
+	if err != nil || id == 0 {
+		return nil, "", err
+	}
+	article, err := models.GetArticle(id)
+	if err != nil || article.ID == 0 || !a.Visibility.Allows(article) {
+		return nil, "", err
+	}
+
+	return nil, article.Slug, nil
+}
+
 func (a *Article) GetAll() ([]*models.Article, error) {
 	var articles []*models.Article
 
-	articles, err := models.GetArticles(a.PageNum, a.PageSize, a.getMaps())
+	articles, err := models.GetArticles(a.PageNum, a.PageSize, a.getMaps(), a.tagFilter(), a.Visibility)
 	if err != nil {
 		return nil, err
 	}
@@ -68,16 +215,120 @@ func (a *Article) GetAll() ([]*models.Article, error) {
 	return articles, nil
 }
 
+// CanModify report whether the caller may edit or delete the article: its
+// author, or a caller granted article:manage
+func (a *Article) CanModify(identity *common.Identity) (bool, error) {
+	if identity == nil {
+		return false, nil
+	}
+
+	manage, err := canManage(identity)
+	if err != nil || manage {
+		return manage, err
+	}
+
+	article, err := models.GetArticle(a.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return article.CreatedByID > 0 && article.CreatedByID == identity.ID, nil
+}
+
+// canManage report whether identity was granted article:manage
+func canManage(identity *common.Identity) (bool, error) {
+	if identity == nil {
+		return false, nil
+	}
+
+	manage, err := auth_service.HasPermission(identity.Role, "article:manage")
+	if err != nil {
+		return false, err
+	}
+
+	return manage && identity.HasScope("article:manage"), nil
+}
+
 func (a *Article) Delete() error {
-	return models.DeleteArticle(a.ID)
+	if err := models.DeleteArticle(a.ID); err != nil {
+		return err
+	}
+
+	if err := search.RemoveArticle(a.ID); err != nil {
+		logging.Error("article_service.Delete remove from search index fail", a.ID, err)
+	}
+	return nil
 }
 
+// Search rank the articles against a.Query and return the requested page
+// with highlighted snippets, plus the total number of matches
+func (a *Article) Search() ([]*SearchResult, int, error) {
+	hits, total, err := search.Search(a.Query, a.Visibility, a.PageNum, a.PageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	articles, err := models.GetArticlesByIDs(ids, a.Visibility)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[int]*models.Article, len(articles))
+	for _, article := range articles {
+		byID[article.ID] = article
+	}
+
+	terms := search.Terms(a.Query)
+	results := make([]*SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		article, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+
+		highlights := make(map[string]string)
+		for field, text := range map[string]string{"title": article.Title, "desc": article.Desc, "content": article.Content} {
+			if snippet := search.Highlight(text, terms, snippetSize); snippet != "" {
+				highlights[field] = snippet
+			}
+		}
+		results = append(results, &SearchResult{Article: article, Score: hit.Score, Highlights: highlights})
+	}
+
+	return results, total, nil
+}
+
+// reindex update the search index after the article changed, a failure only
+// leaves the index stale so it is logged
+func (a *Article) reindex() {
+	article, err := models.GetArticle(a.ID)
+	if err == nil && article.ID > 0 {
+		err = search.IndexArticle(article)
+	}
+	if err != nil {
+		logging.Error("article_service reindex article fail", a.ID, err)
+	}
+}
+
+// ExistByID report whether the article exists and is visible
 func (a *Article) ExistByID() (bool, error) {
-	return models.ExistArticleByID(a.ID)
+	if a.Visibility == nil {
+		return models.ExistArticleByID(a.ID)
+	}
+
+	article, err := models.GetArticle(a.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return article.ID > 0 && a.Visibility.Allows(article), nil
 }
 
 func (a *Article) Count() (int, error) {
-	return models.GetArticleTotal(a.getMaps())
+	return models.GetArticleTotal(a.getMaps(), a.tagFilter(), a.Visibility)
 }
 
 func (a *Article) getMaps() map[string]interface{} {
@@ -86,9 +337,19 @@ func (a *Article) getMaps() map[string]interface{} {
 	if a.State != -1 {
 		maps["state"] = a.State
 	}
+
+	return maps
+}
+
+// tagFilter match the articles tagged with TagIDs, or with the single TagID
+// of v1 clients
+func (a *Article) tagFilter() *models.TagFilter {
+	if len(a.TagIDs) > 0 {
+		return &models.TagFilter{IDs: a.TagIDs, All: a.AllTags}
+	}
 	if a.TagID != -1 {
-		maps["tag_id"] = a.TagID
+		return &models.TagFilter{IDs: []int{a.TagID}}
 	}
 
-	return maps
+	return nil
 }
@@ -0,0 +1,61 @@
+package article_service
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/poster"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/upload"
+)
+
+// GetUrl return the public permalink of the article, at ArticleUrl when it
+// is set. The by-slug lookup keeps it working across renames.
+func GetUrl(article *models.Article) string {
+	base := settings.AppSetting.ArticleUrl
+	if base == "" {
+		base = settings.AppSetting.PrefixUrl + "/articles/"
+	}
+
+	return base + url.PathEscape(article.Slug)
+}
+
+// GeneratePoster render the share poster of the article, reusing the saved
+// one while the article is unchanged, and return the name it is saved under
+func (a *Article) GeneratePoster() (string, error) {
+	article, err := models.GetArticle(a.ID)
+	if err != nil {
+		return "", err
+	}
+
+	p := &poster.Poster{
+		Title: article.Title,
+		Desc:  article.Desc,
+		Cover: coverImage(article.CoverImageUrl),
+		Url:   GetUrl(article),
+	}
+	return p.Generate()
+}
+
+// coverImage return the content of the cover when it was uploaded through
+// /upload/image, nil otherwise. Other urls aren't fetched, the server
+// shouldn't make requests on behalf of whoever set the cover.
+func coverImage(coverImageUrl string) []byte {
+	prefix := upload.GetImageFullUrl("")
+	if !strings.HasPrefix(coverImageUrl, prefix) {
+		return nil
+	}
+	name := path.Clean("/" + strings.TrimPrefix(coverImageUrl, prefix))[1:]
+
+	content, err := ioutil.ReadFile(upload.GetImageFullPath() + name)
+	if err != nil {
+		logging.Warn("article_service.coverImage read cover fail", err)
+		return nil
+	}
+
+	return content
+}
@@ -0,0 +1,79 @@
+package article_service
+
+import (
+	"fmt"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/util"
+)
+
+// diffContext is the number of unchanged lines shown around every change
+const diffContext = 3
+
+// RevisionDiff hold the unified diff of every field that changed between two
+// revisions of an article
+type RevisionDiff struct {
+	From   int               `json:"from"`
+	To     int               `json:"to"`
+	Fields map[string]string `json:"fields"`
+}
+
+func (a *Article) GetRevisions() ([]*models.ArticleRevision, error) {
+	return models.GetArticleRevisions(a.ID, a.PageNum, a.PageSize)
+}
+
+func (a *Article) CountRevisions() (int, error) {
+	return models.GetArticleRevisionTotal(a.ID)
+}
+
+// GetRevision return the revision of the article, its ID is 0 when it doesn't exist
+func (a *Article) GetRevision(revision int) (*models.ArticleRevision, error) {
+	return models.GetArticleRevision(a.ID, revision)
+}
+
+// Restore write the title, desc and content of the revision back as a new
+// edit by identity, the tags of the article are kept
+func (a *Article) Restore(identity *common.Identity, revision *models.ArticleRevision) error {
+	article, err := models.GetArticle(a.ID)
+	if err != nil {
+		return err
+	}
+
+	a.Title = revision.Title
+	a.Desc = revision.Desc
+	a.Content = revision.Content
+	a.TagID = article.TagID
+	a.TagIDs = []int{article.TagID}
+	if len(article.Tags) > 0 {
+		a.TagIDs = make([]int, 0, len(article.Tags))
+		for _, tag := range article.Tags {
+			a.TagIDs = append(a.TagIDs, tag.ID)
+		}
+	}
+	a.RestoredFrom = revision.Revision
+
+	return a.Edit(identity, -1, 0)
+}
+
+// DiffRevisions compare the fields of two revisions line by line
+func DiffRevisions(from, to *models.ArticleRevision) *RevisionDiff {
+	diff := &RevisionDiff{From: from.Revision, To: to.Revision, Fields: make(map[string]string)}
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"desc", from.Desc, to.Desc},
+		{"content", from.Content, to.Content},
+	}
+	for _, f := range fields {
+		fromName := fmt.Sprintf("%s@%d", f.name, from.Revision)
+		toName := fmt.Sprintf("%s@%d", f.name, to.Revision)
+		if d := util.UnifiedDiff(fromName, toName, f.from, f.to, diffContext); d != "" {
+			diff.Fields[f.name] = d
+		}
+	}
+
+	return diff
+}
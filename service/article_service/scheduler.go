@@ -0,0 +1,64 @@
+package article_service
+
+import (
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+)
+
+const (
+	// schedulerActor is recorded as the actor of the articles the scheduler publishes
+	schedulerActor = "scheduler"
+	publishBatch   = 100
+)
+
+// PublishDue publish the scheduled articles whose publish_at has passed and
+// return how many it published. Several instances may run it at once,
+// models.TransitionArticle only lets one of them publish each article.
+func PublishDue() (int, error) {
+	published := 0
+	for {
+		articles, err := models.GetDueArticles(int(time.Now().Unix()), publishBatch)
+		if err != nil {
+			return published, err
+		}
+
+		for _, article := range articles {
+			moved, err := models.TransitionArticle(article.ID, models.ARTICLE_STATE_SCHEDULED,
+				models.ARTICLE_STATE_PUBLISHED, article.PublishAt, 0, schedulerActor)
+			if err != nil {
+				return published, err
+			}
+			if moved {
+				published++
+			}
+		}
+
+		if len(articles) < publishBatch {
+			return published, nil
+		}
+	}
+}
+
+// RunScheduler call PublishDue every interval until stop is closed
+func RunScheduler(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := PublishDue()
+		if err != nil {
+			logging.Error("article_service.RunScheduler publish due articles fail", err)
+		}
+		if published > 0 {
+			logging.Info("article_service.RunScheduler published", published, "article(s)")
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package article_service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miaozhang/webservice/models"
+)
+
+func TestPublishDue(t *testing.T) {
+	conn := useTestDB(t)
+	now := time.Now()
+	past := int(now.Add(-time.Minute).Unix())
+	future := int(now.Add(time.Hour).Unix())
+
+	tests := []struct {
+		name      string
+		state     int
+		publishAt int
+		deleted   bool
+		wantState int
+	}{
+		{"due", models.ARTICLE_STATE_SCHEDULED, past, false, models.ARTICLE_STATE_PUBLISHED},
+		{"due just now", models.ARTICLE_STATE_SCHEDULED, int(now.Unix()), false, models.ARTICLE_STATE_PUBLISHED},
+		{"not due yet", models.ARTICLE_STATE_SCHEDULED, future, false, models.ARTICLE_STATE_SCHEDULED},
+		{"draft with a past publish_at", models.ARTICLE_STATE_DRAFT, past, false, models.ARTICLE_STATE_DRAFT},
+		{"in review with a past publish_at", models.ARTICLE_STATE_IN_REVIEW, past, false, models.ARTICLE_STATE_IN_REVIEW},
+		{"deleted", models.ARTICLE_STATE_SCHEDULED, past, true, models.ARTICLE_STATE_SCHEDULED},
+	}
+	ids := make([]int, len(tests))
+	for i, tt := range tests {
+		ids[i] = addArticle(t, tt.state, tt.publishAt)
+		if tt.deleted {
+			err := conn.Model(&models.Article{}).Where("id = ?", ids[i]).UpdateColumn("deleted_on", now.Unix()).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// two instances running the scheduler at once
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if counts[i], err = PublishDue(); err != nil {
+				t.Errorf("PublishDue() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if counts[0]+counts[1] != 2 {
+		t.Errorf("PublishDue() published %d + %d articles, want 2 in total", counts[0], counts[1])
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var article models.Article
+			if err := conn.First(&article, ids[i]).Error; err != nil {
+				t.Fatal(err)
+			}
+			if article.State != tt.wantState {
+				t.Errorf("state = %s, want %s", StateName(article.State), StateName(tt.wantState))
+			}
+
+			transitions, err := models.GetArticleTransitions(ids[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			published := 0
+			for _, transition := range transitions {
+				if transition.Actor == schedulerActor {
+					published++
+				}
+			}
+			if want := map[bool]int{true: 1}[tt.wantState == models.ARTICLE_STATE_PUBLISHED]; published != want {
+				t.Errorf("scheduler recorded %d transitions, want %d", published, want)
+			}
+		})
+	}
+}
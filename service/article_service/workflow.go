@@ -0,0 +1,188 @@
+package article_service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/service/auth_service"
+)
+
+var (
+	ErrInvalidTransition = errors.New("article can't move to that state")
+	ErrInvalidPublishAt  = errors.New("publish_at must be in the future to schedule an article")
+	ErrPublishForbidden  = errors.New("publishing needs the article:publish permission")
+	ErrStateChanged      = errors.New("article state changed meanwhile")
+)
+
+var stateNames = map[int]string{
+	models.ARTICLE_STATE_DRAFT:     "draft",
+	models.ARTICLE_STATE_IN_REVIEW: "in_review",
+	models.ARTICLE_STATE_SCHEDULED: "scheduled",
+	models.ARTICLE_STATE_PUBLISHED: "published",
+	models.ARTICLE_STATE_ARCHIVED:  "archived",
+}
+
+// transitions list the states an article may move to from each state.
+// Rescheduling is a transition from scheduled to scheduled.
+var transitions = map[int][]int{
+	models.ARTICLE_STATE_DRAFT:     {models.ARTICLE_STATE_IN_REVIEW, models.ARTICLE_STATE_SCHEDULED, models.ARTICLE_STATE_PUBLISHED},
+	models.ARTICLE_STATE_IN_REVIEW: {models.ARTICLE_STATE_DRAFT, models.ARTICLE_STATE_SCHEDULED, models.ARTICLE_STATE_PUBLISHED},
+	models.ARTICLE_STATE_SCHEDULED: {models.ARTICLE_STATE_DRAFT, models.ARTICLE_STATE_SCHEDULED, models.ARTICLE_STATE_PUBLISHED},
+	models.ARTICLE_STATE_PUBLISHED: {models.ARTICLE_STATE_DRAFT, models.ARTICLE_STATE_ARCHIVED},
+	models.ARTICLE_STATE_ARCHIVED:  {models.ARTICLE_STATE_DRAFT},
+}
+
+// ParseState accept the name of a state, e.g. "in_review", or its number
+func ParseState(s string) (int, bool) {
+	for state, name := range stateNames {
+		if name == s {
+			return state, true
+		}
+	}
+
+	state, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	_, ok := stateNames[state]
+	return state, ok
+}
+
+// StateName return the name of the state, e.g. "in_review"
+func StateName(state int) string {
+	return stateNames[state]
+}
+
+// CheckTransition report why identity can't move an article from state from
+// to state to. Scheduling needs a publish_at in the future, scheduling and
+// publishing need the article:publish permission; authors send their
+// articles to review instead.
+func CheckTransition(identity *common.Identity, from, to, publishAt int) error {
+	allowed := false
+	for _, state := range transitions[from] {
+		allowed = allowed || state == to
+	}
+	if !allowed {
+		return ErrInvalidTransition
+	}
+
+	return checkPublish(identity, to, publishAt)
+}
+
+// CheckNewState report why identity can't create an article in the state,
+// new articles can't be archived
+func CheckNewState(identity *common.Identity, state, publishAt int) error {
+	if _, ok := stateNames[state]; !ok || state == models.ARTICLE_STATE_ARCHIVED {
+		return ErrInvalidTransition
+	}
+
+	return checkPublish(identity, state, publishAt)
+}
+
+func checkPublish(identity *common.Identity, to, publishAt int) error {
+	if to != models.ARTICLE_STATE_SCHEDULED && to != models.ARTICLE_STATE_PUBLISHED {
+		return nil
+	}
+	if to == models.ARTICLE_STATE_SCHEDULED && int64(publishAt) <= time.Now().Unix() {
+		return ErrInvalidPublishAt
+	}
+
+	ok, err := canPublish(identity)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPublishForbidden
+	}
+
+	return nil
+}
+
+// canPublish report whether identity was granted article:publish
+func canPublish(identity *common.Identity) (bool, error) {
+	if identity == nil {
+		return false, nil
+	}
+
+	ok, err := auth_service.HasPermission(identity.Role, "article:publish")
+	if err != nil {
+		return false, err
+	}
+
+	return ok && identity.HasScope("article:publish"), nil
+}
+
+// editState return the state an edit of the article by identity leaves it
+// in and its publish_at, -1 to keep the state. to is the state requested
+// with the edit, -1 for none.
+func editState(identity *common.Identity, article *models.Article, to, publishAt int) (int, int, error) {
+	if to == article.State && to != models.ARTICLE_STATE_SCHEDULED {
+		to = -1
+	}
+	if to != -1 {
+		if err := CheckTransition(identity, article.State, to, publishAt); err != nil {
+			return 0, 0, err
+		}
+		return to, transitionPublishAt(article, to, publishAt), nil
+	}
+
+	if article.State != models.ARTICLE_STATE_PUBLISHED && article.State != models.ARTICLE_STATE_SCHEDULED {
+		return -1, 0, nil
+	}
+	ok, err := canPublish(identity)
+	if err != nil || ok {
+		return -1, 0, err
+	}
+
+	return models.ARTICLE_STATE_IN_REVIEW, 0, nil
+}
+
+// transitionPublishAt return the publish_at of the article once moved to
+// state to: now when publishing, publishAt when scheduling, and kept when
+// archiving, so archives remember when they went live
+func transitionPublishAt(article *models.Article, to, publishAt int) int {
+	switch to {
+	case models.ARTICLE_STATE_PUBLISHED:
+		return int(time.Now().Unix())
+	case models.ARTICLE_STATE_SCHEDULED:
+		return publishAt
+	case models.ARTICLE_STATE_ARCHIVED:
+		return article.PublishAt
+	default:
+		return 0
+	}
+}
+
+// Transition move the article to the state on behalf of identity and record
+// who did. Keeping the state is a no-op, except for scheduled which
+// reschedules the article to publishAt.
+func (a *Article) Transition(identity *common.Identity, to, publishAt int) error {
+	article, err := models.GetArticle(a.ID)
+	if err != nil {
+		return err
+	}
+	if article.State == to && to != models.ARTICLE_STATE_SCHEDULED {
+		return nil
+	}
+	if err := CheckTransition(identity, article.State, to, publishAt); err != nil {
+		return err
+	}
+	publishAt = transitionPublishAt(article, to, publishAt)
+
+	moved, err := models.TransitionArticle(a.ID, article.State, to, publishAt, identity.ID, identity.Username)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return ErrStateChanged
+	}
+
+	return nil
+}
+
+func (a *Article) GetTransitions() ([]*models.ArticleTransition, error) {
+	return models.GetArticleTransitions(a.ID)
+}
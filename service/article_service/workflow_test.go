@@ -0,0 +1,205 @@
+package article_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/internal/testdb"
+	"github.com/miaozhang/webservice/models"
+)
+
+var (
+	author = &common.Identity{ID: 1, Username: "alice", Role: models.ROLE_AUTHOR}
+	editor = &common.Identity{ID: 2, Username: "bob", Role: models.ROLE_EDITOR}
+	// editorKey is an API key of the editor that wasn't given article:publish
+	editorKey = &common.Identity{ID: 2, Username: "bob", Role: models.ROLE_EDITOR, ApiKeyID: 1, Scopes: []string{"article:write"}}
+)
+
+// useTestDB run the models on an empty in-memory database with every table
+// and the default role permissions
+func useTestDB(t *testing.T) *gorm.DB {
+	conn := testdb.Open(t, models.UseDB)
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// addArticle create an article of author in the state
+func addArticle(t *testing.T, state, publishAt int) int {
+	id, err := models.AddArticle(map[string]interface{}{
+		"tag_id":        0,
+		"title":         "article",
+		"desc":          "",
+		"content":       "",
+		"created_by":    author.Username,
+		"created_by_id": author.ID,
+		"state":         state,
+		"publish_at":    publishAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func TestCheckTransition(t *testing.T) {
+	useTestDB(t)
+	future := int(time.Now().Add(time.Hour).Unix())
+	past := int(time.Now().Add(-time.Hour).Unix())
+
+	const (
+		draft     = models.ARTICLE_STATE_DRAFT
+		inReview  = models.ARTICLE_STATE_IN_REVIEW
+		scheduled = models.ARTICLE_STATE_SCHEDULED
+		published = models.ARTICLE_STATE_PUBLISHED
+		archived  = models.ARTICLE_STATE_ARCHIVED
+	)
+	states := []int{draft, inReview, scheduled, published, archived}
+	// allowed[from][to] for a caller who may publish
+	allowed := map[int]map[int]bool{
+		draft:     {inReview: true, scheduled: true, published: true},
+		inReview:  {draft: true, scheduled: true, published: true},
+		scheduled: {draft: true, scheduled: true, published: true},
+		published: {draft: true, archived: true},
+		archived:  {draft: true},
+	}
+	for _, from := range states {
+		for _, to := range states {
+			want := error(nil)
+			if !allowed[from][to] {
+				want = ErrInvalidTransition
+			}
+			if err := CheckTransition(editor, from, to, future); err != want {
+				t.Errorf("CheckTransition(%s -> %s) error = %v, want %v", StateName(from), StateName(to), err, want)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		identity  *common.Identity
+		from, to  int
+		publishAt int
+		want      error
+	}{
+		{"author sends a draft to review", author, draft, inReview, 0, nil},
+		{"author can't publish", author, inReview, published, 0, ErrPublishForbidden},
+		{"author can't schedule", author, draft, scheduled, future, ErrPublishForbidden},
+		{"author may unpublish their article", author, published, draft, 0, nil},
+		{"editor publishes", editor, inReview, published, 0, nil},
+		{"scheduling in the past", editor, draft, scheduled, past, ErrInvalidPublishAt},
+		{"rescheduling in the past", editor, scheduled, scheduled, past, ErrInvalidPublishAt},
+		{"publishing ignores publish_at", editor, draft, published, past, nil},
+		{"API key without the publish scope", editorKey, draft, published, 0, ErrPublishForbidden},
+		{"anonymous", nil, draft, published, 0, ErrPublishForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckTransition(tt.identity, tt.from, tt.to, tt.publishAt); err != tt.want {
+				t.Errorf("CheckTransition() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditState(t *testing.T) {
+	useTestDB(t)
+	future := int(time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name          string
+		identity      *common.Identity
+		state         int
+		to, publishAt int
+		wantState     int
+		wantErr       error
+	}{
+		{"author edits a draft", author, models.ARTICLE_STATE_DRAFT, -1, 0, -1, nil},
+		{"author edits a published article", author, models.ARTICLE_STATE_PUBLISHED, -1, 0, models.ARTICLE_STATE_IN_REVIEW, nil},
+		{"author edits a scheduled article", author, models.ARTICLE_STATE_SCHEDULED, -1, 0, models.ARTICLE_STATE_IN_REVIEW, nil},
+		{"author asks to keep it published", author, models.ARTICLE_STATE_PUBLISHED, models.ARTICLE_STATE_PUBLISHED, 0, models.ARTICLE_STATE_IN_REVIEW, nil},
+		{"author edits an article in review", author, models.ARTICLE_STATE_IN_REVIEW, -1, 0, -1, nil},
+		{"editor edits a published article", editor, models.ARTICLE_STATE_PUBLISHED, -1, 0, -1, nil},
+		{"editor edits and publishes", editor, models.ARTICLE_STATE_IN_REVIEW, models.ARTICLE_STATE_PUBLISHED, 0, models.ARTICLE_STATE_PUBLISHED, nil},
+		{"editor edits and reschedules", editor, models.ARTICLE_STATE_SCHEDULED, models.ARTICLE_STATE_SCHEDULED, future, models.ARTICLE_STATE_SCHEDULED, nil},
+		{"edit with an invalid transition", editor, models.ARTICLE_STATE_ARCHIVED, models.ARTICLE_STATE_PUBLISHED, 0, 0, ErrInvalidTransition},
+		{"author edits and publishes", author, models.ARTICLE_STATE_DRAFT, models.ARTICLE_STATE_PUBLISHED, 0, 0, ErrPublishForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article := &models.Article{State: tt.state, CreatedByID: author.ID}
+			state, publishAt, err := editState(tt.identity, article, tt.to, tt.publishAt)
+			if err != tt.wantErr {
+				t.Fatalf("editState() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if state != tt.wantState {
+				t.Errorf("editState() state = %d, want %d", state, tt.wantState)
+			}
+			if state == models.ARTICLE_STATE_SCHEDULED && publishAt != tt.publishAt {
+				t.Errorf("editState() publish_at = %d, want %d", publishAt, tt.publishAt)
+			}
+		})
+	}
+}
+
+func TestEditSendsBackToReview(t *testing.T) {
+	useTestDB(t)
+	id := addArticle(t, models.ARTICLE_STATE_PUBLISHED, int(time.Now().Unix()))
+
+	articleService := Article{ID: id, Title: "edited", ModifiedBy: author.Username, ModifiedByID: author.ID}
+	if err := articleService.Edit(author, -1, 0); err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+
+	article, err := models.GetArticle(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "edited" || article.State != models.ARTICLE_STATE_IN_REVIEW {
+		t.Errorf("Edit() left title %q in state %s, want the edit in review", article.Title, StateName(article.State))
+	}
+}
+
+func TestTransition(t *testing.T) {
+	useTestDB(t)
+	id := addArticle(t, models.ARTICLE_STATE_IN_REVIEW, 0)
+	articleService := Article{ID: id}
+
+	if err := articleService.Transition(author, models.ARTICLE_STATE_PUBLISHED, 0); err != ErrPublishForbidden {
+		t.Fatalf("Transition() by the author error = %v, want %v", err, ErrPublishForbidden)
+	}
+	if err := articleService.Transition(editor, models.ARTICLE_STATE_PUBLISHED, 0); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	// publishing again is a no-op
+	if err := articleService.Transition(editor, models.ARTICLE_STATE_PUBLISHED, 0); err != nil {
+		t.Fatalf("Transition() to the same state error = %v", err)
+	}
+
+	article, err := models.GetArticle(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.State != models.ARTICLE_STATE_PUBLISHED || article.PublishAt == 0 {
+		t.Errorf("Transition() left state %s, publish_at %d, want published now", StateName(article.State), article.PublishAt)
+	}
+
+	transitions, err := articleService.GetTransitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the creation in review, then the publication
+	if len(transitions) != 2 || transitions[0].ToState != models.ARTICLE_STATE_PUBLISHED || transitions[0].ActorID != editor.ID {
+		t.Errorf("GetTransitions() = %+v, want the publication by the editor first", transitions)
+	}
+}
@@ -1,14 +1,97 @@
 package auth_service
 
 import (
+	"sync"
+
+	"github.com/miaozhang/webservice/logging"
 	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/util"
 )
 
 type Auth struct {
+	ID       int
 	Username string
 	Password string
+	Role     string
+	Client   Client
+
+	MfaEnabled bool
 }
 
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// Check verify the credentials, upgrading a legacy plaintext or outdated
+// password hash on success. a.ID, a.Role and a.MfaEnabled are set when the
+// credentials are valid.
 func (a *Auth) Check() (bool, error) {
-	return models.CheckAuth(a.Username, a.Password)
+	auth, err := models.GetAuthByUsername(a.Username)
+	if err != nil {
+		return false, err
+	}
+
+	if auth.ID == 0 {
+		// spend the same time as a real check so usernames can't be probed
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = util.HashPassword("dummy-password")
+		})
+		util.VerifyPassword(dummyHash, a.Password)
+		return false, nil
+	}
+
+	match, rehash, err := util.VerifyPassword(auth.Password, a.Password)
+	if err != nil || !match {
+		return false, err
+	}
+	if auth.State != models.AUTH_STATE_ACTIVE {
+		return false, nil
+	}
+
+	if rehash {
+		hashed, err := util.HashPassword(a.Password)
+		if err == nil {
+			err = models.UpdateAuthPassword(auth.ID, hashed)
+		}
+		if err != nil {
+			logging.Warn("auth_service.Check rehash password fail", auth.ID, err)
+		}
+	}
+
+	a.ID = auth.ID
+	a.Role = auth.Role
+	a.MfaEnabled = auth.TotpEnabled
+	return true, nil
+}
+
+// MigratePasswords hash every password still stored in plaintext and
+// return how many rows were updated
+func MigratePasswords() (int, error) {
+	if err := models.MigrateAuthPasswordColumn(); err != nil {
+		return 0, err
+	}
+
+	auths, err := models.GetAuths()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, auth := range auths {
+		if util.IsPasswordHashed(auth.Password) {
+			continue
+		}
+
+		hashed, err := util.HashPassword(auth.Password)
+		if err != nil {
+			return count, err
+		}
+		if err := models.UpdateAuthPassword(auth.ID, hashed); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
 }
@@ -0,0 +1,92 @@
+package auth_service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+const maxLoginBackoff = time.Hour
+
+func usernameAttemptKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginBlockedFor return how long logins for the username or from the client
+// IP are still refused, 0 when the attempt may go ahead
+func LoginBlockedFor(username, ip string) (time.Duration, error) {
+	userBlock, err := util.Attempts().BlockedFor(usernameAttemptKey(username))
+	if err != nil {
+		return 0, err
+	}
+
+	ipBlock, err := util.Attempts().BlockedFor(ipAttemptKey(ip))
+	if err != nil {
+		return 0, err
+	}
+
+	if ipBlock > userBlock {
+		return ipBlock, nil
+	}
+	return userBlock, nil
+}
+
+// LoginFailed record a failed login for the username and client IP. Each
+// failure blocks the next attempt with an exponential backoff, reaching the
+// configured threshold locks the username or IP out for LoginLockout.
+func LoginFailed(username, ip string) (time.Duration, error) {
+	userBlock, err := recordFailure(usernameAttemptKey(username), settings.AppSetting.LoginMaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	ipBlock, err := recordFailure(ipAttemptKey(ip), settings.AppSetting.LoginIPMaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	if ipBlock > userBlock {
+		return ipBlock, nil
+	}
+	return userBlock, nil
+}
+
+// LoginSucceeded clear the failures of the username. The client IP keeps its
+// failures so one valid account can't be used to reset them.
+func LoginSucceeded(username string) error {
+	return util.Attempts().Reset(usernameAttemptKey(username))
+}
+
+func recordFailure(key string, maxAttempts int) (time.Duration, error) {
+	lockout := settings.AppSetting.LoginLockout
+	failures, err := util.Attempts().Fail(key, lockout)
+	if err != nil {
+		return 0, err
+	}
+
+	var block time.Duration
+	if maxAttempts > 0 && failures >= maxAttempts {
+		block = lockout
+		logging.Warn("auth_service.LoginFailed lockout", key, failures, lockout)
+	} else {
+		block = settings.AppSetting.LoginBackoff << uint(failures-1)
+		if block <= 0 || block > maxLoginBackoff {
+			block = maxLoginBackoff
+		}
+		if block > lockout {
+			block = lockout
+		}
+	}
+
+	if block <= 0 {
+		return 0, nil
+	}
+	return block, util.Attempts().Block(key, block)
+}
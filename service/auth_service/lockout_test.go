@@ -0,0 +1,107 @@
+package auth_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+func setupLockout() {
+	settings.AppSetting.LoginMaxAttempts = 3
+	settings.AppSetting.LoginIPMaxAttempts = 5
+	settings.AppSetting.LoginBackoff = time.Second
+	settings.AppSetting.LoginLockout = 15 * time.Minute
+	settings.AppSetting.LoginAttemptStore = "memory"
+	util.Setup()
+}
+
+func TestLoginFailed(t *testing.T) {
+	setupLockout()
+
+	// the block doubles with every failure until the lockout
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 15 * time.Minute} {
+		block, err := LoginFailed("alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block != want {
+			t.Errorf("failure %d: LoginFailed() = %v, want %v", i+1, block, want)
+		}
+	}
+
+	blocked, err := LoginBlockedFor("Alice", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocked < 14*time.Minute {
+		t.Errorf("LoginBlockedFor() of the locked out username from another IP = %v, want the lockout", blocked)
+	}
+
+	if err := LoginSucceeded("alice"); err != nil {
+		t.Fatal(err)
+	}
+	blocked, err = LoginBlockedFor("alice", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocked != 0 {
+		t.Errorf("LoginBlockedFor() after a successful login = %v, want 0", blocked)
+	}
+}
+
+func TestLoginFailedByIP(t *testing.T) {
+	setupLockout()
+
+	// one failure for each of many usernames still locks the IP out
+	var block time.Duration
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		var err error
+		if block, err = LoginFailed(username, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if block != 15*time.Minute {
+		t.Errorf("LoginFailed() at the IP threshold = %v, want the lockout", block)
+	}
+
+	// a valid login from the IP doesn't clear it
+	if err := LoginSucceeded("a"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		ip      string
+		blocked bool
+	}{
+		{"same IP", "10.0.0.1", true},
+		{"other IP", "10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		blocked, err := LoginBlockedFor("f", tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (blocked > 0) != tt.blocked {
+			t.Errorf("%s: LoginBlockedFor() = %v, want blocked %v", tt.name, blocked, tt.blocked)
+		}
+	}
+}
+
+func TestLoginBackoffCappedByLockout(t *testing.T) {
+	setupLockout()
+	settings.AppSetting.LoginMaxAttempts = 0
+	settings.AppSetting.LoginIPMaxAttempts = 0
+	settings.AppSetting.LoginBackoff = 10 * time.Minute
+
+	for i, want := range []time.Duration{10 * time.Minute, 15 * time.Minute, 15 * time.Minute} {
+		block, err := LoginFailed("alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block != want {
+			t.Errorf("failure %d: LoginFailed() = %v, want %v", i+1, block, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package auth_service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+const (
+	recoveryCodeCount = 10
+	totpQRCodeSize    = 256
+)
+
+var (
+	ErrTotpCodeInvalid    = errors.New("totp code is invalid")
+	ErrTotpNotEnrolled    = errors.New("totp enrollment has not been started")
+	ErrTotpAlreadyEnabled = errors.New("totp is already enabled")
+	ErrMfaTokenInvalid    = errors.New("mfa token is invalid or expired")
+)
+
+type TotpEnrollment struct {
+	Secret string
+	URI    string
+	QRCode []byte
+}
+
+// EnrollTotp start TOTP enrollment with a new secret. The secret is only
+// used for logins once ConfirmTotp checked a first code.
+func EnrollTotp(authID int) (*TotpEnrollment, error) {
+	auth, err := models.GetAuth(authID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.TotpEnabled {
+		return nil, ErrTotpAlreadyEnabled
+	}
+
+	secret, err := util.GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	uri := util.TotpURI(settings.AppSetting.JwtIssuer, auth.Username, secret)
+	qrCode, err := util.QRCodePNG(uri, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.UpdateAuthTotp(authID, map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TotpEnrollment{Secret: secret, URI: uri, QRCode: qrCode}, nil
+}
+
+// ConfirmTotp enable TOTP once the user proved their app produces valid codes
+// and return the plaintext recovery codes, which are only stored hashed
+func ConfirmTotp(authID int, code string) ([]string, error) {
+	auth, err := models.GetAuth(authID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.TotpEnabled {
+		return nil, ErrTotpAlreadyEnabled
+	}
+	if auth.TotpSecret == "" {
+		return nil, ErrTotpNotEnrolled
+	}
+
+	ok, err := verifyTotp(auth, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTotpCodeInvalid
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := models.ReplaceRecoveryCodes(authID, hashes); err != nil {
+		return nil, err
+	}
+	if err := models.UpdateAuthTotp(authID, map[string]interface{}{"totp_enabled": true}); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTotp turn the second factor off, code may be a TOTP or a recovery code
+func DisableTotp(authID int, code string) error {
+	auth, err := models.GetAuth(authID)
+	if err != nil {
+		return err
+	}
+	if !auth.TotpEnabled {
+		return ErrTotpNotEnrolled
+	}
+
+	ok, err := verifySecondFactor(auth, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTotpCodeInvalid
+	}
+
+	if err := models.DeleteRecoveryCodes(authID); err != nil {
+		return err
+	}
+
+	return models.UpdateAuthTotp(authID, map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	})
+}
+
+// IssueMfaToken issue the short-lived token that CompleteMfa exchanges for
+// the real tokens once the second factor was checked
+func (a *Auth) IssueMfaToken() (string, error) {
+	return util.GenerateMfaToken(a.ID, a.Username, a.Role)
+}
+
+// CompleteMfa check the second factor of a login started with the password
+// and issue the access/refresh pair. authID is returned as soon as the mfa
+// token is valid so failed codes can be counted.
+func CompleteMfa(mfaToken, code string, client Client) (authID int, tokens *Tokens, err error) {
+	claims, err := util.ParseMfaToken(mfaToken)
+	if err != nil {
+		return 0, nil, ErrMfaTokenInvalid
+	}
+
+	auth, err := models.GetAuth(claims.UserID())
+	if err != nil {
+		return 0, nil, err
+	}
+	if auth.ID == 0 || auth.State != models.AUTH_STATE_ACTIVE || !auth.TotpEnabled {
+		return 0, nil, ErrMfaTokenInvalid
+	}
+
+	ok, err := verifySecondFactor(auth, code)
+	if err != nil {
+		return auth.ID, nil, err
+	}
+	if !ok {
+		return auth.ID, nil, ErrTotpCodeInvalid
+	}
+
+	a := &Auth{ID: auth.ID, Username: auth.Username, Role: auth.Role, Client: client}
+	tokens, err = a.IssueTokens()
+	return auth.ID, tokens, err
+}
+
+// MfaBlockedFor return how long second factor attempts for the user are refused
+func MfaBlockedFor(authID int) (time.Duration, error) {
+	return util.Attempts().BlockedFor(mfaAttemptKey(authID))
+}
+
+// MfaFailed record a wrong second factor code with the same backoff and
+// lockout as failed passwords
+func MfaFailed(authID int) (time.Duration, error) {
+	return recordFailure(mfaAttemptKey(authID), settings.AppSetting.LoginMaxAttempts)
+}
+
+func MfaSucceeded(authID int) error {
+	return util.Attempts().Reset(mfaAttemptKey(authID))
+}
+
+func mfaAttemptKey(authID int) string {
+	return "mfa:" + strconv.Itoa(authID)
+}
+
+func verifySecondFactor(auth *models.Auth, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		return verifyTotp(auth, code)
+	}
+
+	return useRecoveryCode(auth.ID, code)
+}
+
+func verifyTotp(auth *models.Auth, code string) (bool, error) {
+	step, ok := util.ValidateTotp(auth.TotpSecret, code, time.Now(), auth.TotpLastStep)
+	if !ok {
+		return false, nil
+	}
+
+	// a code is only good once, even within its time step
+	return models.UseAuthTotpStep(auth.ID, step)
+}
+
+func useRecoveryCode(authID int, code string) (bool, error) {
+	codes, err := models.GetUnusedRecoveryCodes(authID)
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.ToLower(strings.Replace(code, "-", "", -1))
+	for _, rc := range codes {
+		match, _, err := util.VerifyPassword(rc.CodeHash, code)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return models.UseRecoveryCode(rc.ID)
+		}
+	}
+
+	return false, nil
+}
+
+// newRecoveryCodes return recoveryCodeCount codes formatted as xxxxx-xxxxx and their hashes
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := util.RandomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash, err := util.HashPassword(code)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hash)
+	}
+
+	return codes, hashes, nil
+}
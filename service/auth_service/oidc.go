@@ -0,0 +1,165 @@
+package auth_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/oidc"
+	"github.com/miaozhang/webservice/util"
+)
+
+// OidcStateExpire is how long the user has to sign in at the provider
+const OidcStateExpire = 10 * time.Minute
+
+var (
+	ErrOidcStateInvalid = errors.New("oidc state is invalid or expired")
+	ErrOidcLoginFailed  = errors.New("oidc login failed")
+)
+
+// OidcLogin start an authorization code flow with PKCE and return the URL
+// of the provider plus the state token to keep in the browser until the
+// callback
+func OidcLogin(providerName string) (authURL, stateToken string, err error) {
+	provider, err := oidc.GetProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := util.RandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, stateToken, err := util.GenerateOidcState(providerName, nonce, verifier, OidcStateExpire)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err = provider.AuthCodeURL(claims.Id, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, stateToken, nil
+}
+
+// OidcCallback finish the flow started by OidcLogin: redeem the code and map
+// the provider subject to a local Auth, created on the first login. Like
+// after Check, the caller issues the tokens, or an MFA token when
+// a.MfaEnabled. Local accounts are never linked by username or email so an
+// identity provider can't take over an existing account.
+func OidcCallback(providerName, stateToken, state, code string, client Client) (*Auth, error) {
+	provider, err := oidc.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := util.ParseOidcState(stateToken)
+	if err != nil || claims.Provider != providerName || claims.Id != state {
+		return nil, ErrOidcStateInvalid
+	}
+
+	idToken, err := provider.Exchange(code, claims.Verifier, claims.Nonce)
+	if err != nil {
+		logging.Warn("auth_service.OidcCallback exchange fail", providerName, err)
+		return nil, ErrOidcLoginFailed
+	}
+
+	auth, err := oidcAuth(provider, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if auth.State != models.AUTH_STATE_ACTIVE {
+		return nil, ErrOidcLoginFailed
+	}
+
+	return &Auth{
+		ID:         auth.ID,
+		Username:   auth.Username,
+		Role:       auth.Role,
+		Client:     client,
+		MfaEnabled: auth.TotpEnabled,
+	}, nil
+}
+
+// oidcAuth return the Auth linked to the ID token subject, creating it just
+// in time on the first login
+func oidcAuth(provider *oidc.Provider, idToken *oidc.IDToken) (*models.Auth, error) {
+	identity, err := models.GetAuthIdentity(provider.Name, idToken.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity.ID > 0 {
+		auth, err := models.GetAuth(identity.AuthID)
+		if err != nil {
+			return nil, err
+		}
+		if auth.ID == 0 {
+			return nil, ErrOidcLoginFailed
+		}
+		return auth, nil
+	}
+
+	username, err := oidcUsername(provider, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// the account can only sign in through the provider until a password is reset
+	password, err := util.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := util.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &models.Auth{
+		Username: username,
+		Password: hashed,
+		Role:     provider.Config.Role,
+		State:    models.AUTH_STATE_ACTIVE,
+	}
+	if verified, _ := idToken.Claims["email_verified"].(bool); verified {
+		auth.Email = idToken.StringClaim("email")
+	}
+	if !models.IsRole(auth.Role) {
+		auth.Role = models.ROLE_READER
+	}
+
+	if err := models.AddAuthWithIdentity(auth, provider.Name, idToken.Subject); err != nil {
+		return nil, err
+	}
+	logging.Info("auth_service.OidcCallback created user", provider.Name, auth.ID, auth.Username)
+
+	return auth, nil
+}
+
+// oidcUsername pick the username of a new user from the configured claim,
+// falling back to a name derived from the provider subject when the claim is
+// missing or the username is already taken
+func oidcUsername(provider *oidc.Provider, idToken *oidc.IDToken) (string, error) {
+	username := strings.TrimSpace(idToken.StringClaim(provider.Config.UsernameClaim))
+	if username != "" && len(username) <= 50 {
+		exists, err := models.ExistAuthByUsername(username)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+	}
+
+	sum := sha256.Sum256([]byte(idToken.Subject))
+	return provider.Name + "_" + hex.EncodeToString(sum[:8]), nil
+}
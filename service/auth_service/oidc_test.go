@@ -0,0 +1,149 @@
+package auth_service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/oidc"
+	"github.com/miaozhang/webservice/oidc/oidctest"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+const testProvider = "mock"
+
+// setupOidc run the models on an empty in-memory database and configure the
+// mock provider as testProvider
+func setupOidc(t *testing.T) *oidctest.Provider {
+	useTestDB(t, &models.Auth{}, &models.AuthIdentity{})
+
+	mock, err := oidctest.NewProvider("webservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+
+	settings.OidcSettings = map[string]*settings.Oidc{
+		testProvider: {
+			Issuer:        mock.Issuer(),
+			ClientId:      mock.ClientID,
+			RedirectUrl:   "http://127.0.0.1:8000/auth/oidc/mock/callback",
+			Scopes:        []string{"openid"},
+			UsernameClaim: "preferred_username",
+			Role:          models.ROLE_AUTHOR,
+		},
+	}
+	oidc.Setup()
+
+	return mock
+}
+
+// oidcLogin go through the whole flow for the current subject of mock
+func oidcLogin(t *testing.T, mock *oidctest.Provider) (*Auth, error) {
+	authURL, stateToken, err := OidcLogin(testProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := mock.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := util.ParseOidcState(stateToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return OidcCallback(testProvider, stateToken, claims.Id, code, Client{})
+}
+
+func TestOidcCallbackState(t *testing.T) {
+	mock := setupOidc(t)
+
+	authURL, stateToken, err := OidcLogin(testProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := util.ParseOidcState(stateToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherProviderState, err := util.GenerateOidcState("other", claims.Nonce, claims.Verifier, OidcStateExpire)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		stateToken string
+		state      string
+	}{
+		{name: "state mismatch", stateToken: stateToken, state: "other-state"},
+		{name: "missing state token", stateToken: "", state: claims.Id},
+		{name: "tampered state token", stateToken: stateToken + "x", state: claims.Id},
+		{name: "state token of another provider", stateToken: otherProviderState, state: claims.Id},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := mock.Authorize(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OidcCallback(testProvider, tt.stateToken, tt.state, code, Client{}); err != ErrOidcStateInvalid {
+				t.Errorf("OidcCallback() error = %v, want %v", err, ErrOidcStateInvalid)
+			}
+		})
+	}
+}
+
+func TestOidcCallbackCreatesAuth(t *testing.T) {
+	mock := setupOidc(t)
+	mock.Claims["preferred_username"] = "alice"
+
+	first, err := oidcLogin(t, mock)
+	if err != nil {
+		t.Fatalf("OidcCallback() error = %v", err)
+	}
+	if first.ID == 0 || first.Username != "alice" || first.Role != models.ROLE_AUTHOR || first.MfaEnabled {
+		t.Errorf("OidcCallback() = %+v, want a new author alice without MFA", first)
+	}
+
+	again, err := oidcLogin(t, mock)
+	if err != nil {
+		t.Fatalf("OidcCallback() error = %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("OidcCallback() of the same subject = Auth %d, want %d", again.ID, first.ID)
+	}
+
+	// another subject claiming a taken username gets a derived one
+	mock.Subject = "other-subject"
+	other, err := oidcLogin(t, mock)
+	if err != nil {
+		t.Fatalf("OidcCallback() error = %v", err)
+	}
+	if other.ID == first.ID || other.Username == "alice" || !strings.HasPrefix(other.Username, testProvider+"_") {
+		t.Errorf("OidcCallback() of a colliding username = %+v, want a new Auth named %s_<hash>", other, testProvider)
+	}
+}
+
+func TestOidcCallbackRequiresMfa(t *testing.T) {
+	mock := setupOidc(t)
+
+	auth, err := oidcLogin(t, mock)
+	if err != nil {
+		t.Fatalf("OidcCallback() error = %v", err)
+	}
+	if err := models.EditAuth(auth.ID, map[string]interface{}{"totp_enabled": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err = oidcLogin(t, mock)
+	if err != nil {
+		t.Fatalf("OidcCallback() error = %v", err)
+	}
+	if !auth.MfaEnabled {
+		t.Error("OidcCallback() MfaEnabled = false for a user with TOTP enabled")
+	}
+}
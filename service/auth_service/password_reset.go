@@ -0,0 +1,118 @@
+package auth_service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/mailer"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// forgotPasswordInterval is the minimum time between two reset mails for the same user
+const forgotPasswordInterval = time.Minute
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+// ForgotPassword mail a reset link to the user. Unknown usernames, users
+// without an email and throttled requests are silently ignored so the
+// response doesn't reveal which accounts exist.
+func ForgotPassword(username string) error {
+	key := "reset:" + strings.ToLower(username)
+	blockedFor, err := util.Attempts().BlockedFor(key)
+	if err != nil || blockedFor > 0 {
+		return err
+	}
+	if err := util.Attempts().Block(key, forgotPasswordInterval); err != nil {
+		return err
+	}
+
+	auth, err := models.GetAuthByUsername(username)
+	if err != nil {
+		return err
+	}
+	if auth.ID == 0 || auth.Email == "" || auth.State != models.AUTH_STATE_ACTIVE {
+		return nil
+	}
+
+	token, err := util.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	expire := settings.AppSetting.PasswordResetExpire
+	expiresOn := time.Now().Add(expire).Unix()
+	if err := models.AddPasswordReset(auth.ID, util.HashToken(token), int(expiresOn)); err != nil {
+		return err
+	}
+
+	msg := &mailer.Message{
+		To:      auth.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nOpen the link below to choose a new password:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once. If you didn't ask for it, ignore this mail.\n",
+			auth.Username, resetLink(token), expire),
+	}
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			logging.Error("auth_service.ForgotPassword send mail fail", auth.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword consume the reset token, set the new password and log the
+// user out everywhere
+func ResetPassword(token, password string) error {
+	reset, err := models.GetPasswordResetByHash(util.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if reset.ID == 0 {
+		return ErrResetTokenInvalid
+	}
+
+	ok, err := models.UsePasswordReset(reset.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrResetTokenInvalid
+	}
+
+	auth, err := models.GetAuth(reset.AuthID)
+	if err != nil {
+		return err
+	}
+	if auth.ID == 0 || auth.State != models.AUTH_STATE_ACTIVE {
+		return ErrResetTokenInvalid
+	}
+
+	hashed, err := util.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := models.UpdateAuthPassword(auth.ID, hashed); err != nil {
+		return err
+	}
+	if err := LoginSucceeded(auth.Username); err != nil {
+		logging.Warn("auth_service.ResetPassword reset failed logins fail", auth.ID, err)
+	}
+
+	return RevokeUserTokens(auth.ID, true)
+}
+
+func resetLink(token string) string {
+	link := settings.AppSetting.PasswordResetUrl
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+
+	return link + sep + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,31 @@
+package auth_service
+
+import (
+	"errors"
+
+	"github.com/miaozhang/webservice/models"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// HasPermission report whether role grants permission, e.g. "article:delete"
+func HasPermission(role, permission string) (bool, error) {
+	if role == "" {
+		return false, nil
+	}
+
+	return models.ExistRolePermission(role, permission)
+}
+
+// SetRole change the role of a user and revoke the tokens that still carry the old one
+func SetRole(authID int, role string) error {
+	if !models.IsRole(role) {
+		return ErrUnknownRole
+	}
+
+	if err := models.UpdateAuthRole(authID, role); err != nil {
+		return err
+	}
+
+	return RevokeUserTokens(authID, false)
+}
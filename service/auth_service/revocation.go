@@ -0,0 +1,77 @@
+package auth_service
+
+import (
+	"time"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// RevocationSyncInterval is how soon the revocations of other processes
+// reach a memory denylist
+const RevocationSyncInterval = 5 * time.Second
+
+// revocationSyncLag is how far back each sync looks again, for revocations
+// committed while the previous one ran
+const revocationSyncLag = 10 * time.Second
+
+// SyncTokenRevocations copy to the denylist of this process the user and
+// session revocations recorded in the database after since, in unix
+// milliseconds, and return where the next sync starts. It is how the
+// revocations made by another process, e.g. the revoke-tokens command or
+// another instance ending a session, reach a memory denylist.
+func SyncTokenRevocations(since int64) (int64, error) {
+	next := unixMilli(time.Now().Add(-revocationSyncLag))
+	auths, err := models.GetTokenRevocationsSince(since)
+	if err != nil {
+		return since, err
+	}
+
+	for _, auth := range auths {
+		at := time.Unix(0, auth.TokensRevokedAt*int64(time.Millisecond))
+		if err := util.RevokeUserTokens(auth.ID, at); err != nil {
+			return since, err
+		}
+	}
+
+	// sessions are revoked to the second
+	familyIDs, err := models.GetSessionsRevokedSince(int(since / 1000))
+	if err != nil {
+		return since, err
+	}
+	for _, familyID := range familyIDs {
+		if err := util.RevokeSession(familyID); err != nil {
+			return since, err
+		}
+	}
+
+	return next, nil
+}
+
+// RunRevocationSync call SyncTokenRevocations every interval until stop is
+// closed, starting with the revocations still in effect
+func RunRevocationSync(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := unixMilli(time.Now().Add(-settings.AppSetting.JwtExpire))
+	for {
+		var err error
+		since, err = SyncTokenRevocations(since)
+		if err != nil {
+			logging.Error("auth_service.RunRevocationSync sync token revocations fail", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
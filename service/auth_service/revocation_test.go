@@ -0,0 +1,100 @@
+package auth_service
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/util"
+)
+
+func TestSyncTokenRevocations(t *testing.T) {
+	useTestDB(t, &models.Auth{}, &models.Session{})
+	if err := models.AddAuth("alice", "", "", models.ROLE_READER); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := models.GetAuthByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued := time.Now()
+	claims := &util.Claims{
+		IssuedAtMilli:  unixMilli(issued),
+		StandardClaims: jwt.StandardClaims{Id: "token", Subject: "1", IssuedAt: issued.Unix()},
+	}
+
+	// what revoke-tokens records from another process
+	since := unixMilli(issued.Add(-time.Hour))
+	if err := models.RevokeAuthTokensAt(auth.ID, unixMilli(issued.Add(time.Millisecond))); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := util.IsTokenRevoked(claims); revoked {
+		t.Fatal("IsTokenRevoked() = true before the sync")
+	}
+
+	since, err = SyncTokenRevocations(since)
+	if err != nil {
+		t.Fatalf("SyncTokenRevocations() error = %v", err)
+	}
+	if revoked, _ := util.IsTokenRevoked(claims); !revoked {
+		t.Error("IsTokenRevoked() = false after the sync")
+	}
+
+	// an older revocation doesn't move the recorded one back
+	if err := models.RevokeAuthTokensAt(auth.ID, unixMilli(issued.Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	revocations, err := models.GetTokenRevocationsSince(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revocations) != 1 || revocations[0].TokensRevokedAt != unixMilli(issued.Add(time.Millisecond)) {
+		t.Errorf("GetTokenRevocationsSince() = %+v, want the latest revocation again within the sync lag", revocations)
+	}
+}
+
+func TestSyncSessionRevocations(t *testing.T) {
+	useTestDB(t, &models.Auth{}, &models.Session{})
+	since := unixMilli(time.Now().Add(-time.Hour))
+	for _, familyID := range []string{"ended", "active"} {
+		if err := models.AddSession(1, familyID, "", "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// what another instance records when the user signs out of a device
+	if err := models.RevokeSession("ended"); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(sessionID string) *util.Claims {
+		return &util.Claims{
+			SessionID:      sessionID,
+			IssuedAtMilli:  unixMilli(time.Now()),
+			StandardClaims: jwt.StandardClaims{Id: sessionID + "-token", Subject: "1", IssuedAt: time.Now().Unix()},
+		}
+	}
+	if revoked, _ := util.IsTokenRevoked(claims("ended")); revoked {
+		t.Fatal("IsTokenRevoked() = true before the sync")
+	}
+
+	if _, err := SyncTokenRevocations(since); err != nil {
+		t.Fatalf("SyncTokenRevocations() error = %v", err)
+	}
+
+	tests := []struct {
+		sessionID string
+		want      bool
+	}{
+		{"ended", true},
+		{"active", false},
+	}
+	for _, tt := range tests {
+		if revoked, _ := util.IsTokenRevoked(claims(tt.sessionID)); revoked != tt.want {
+			t.Errorf("IsTokenRevoked() of session %s = %v, want %v", tt.sessionID, revoked, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package auth_service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/util"
+)
+
+// sessionTouchInterval limit how often the activity of a session is written
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Client describe the device a session was started from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+type Session struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+var (
+	sessionTouchMu sync.Mutex
+	sessionTouched = make(map[string]time.Time)
+)
+
+// GetSessions list the active sessions of the user, flagging the one
+// currentID belongs to
+func GetSessions(authID int, currentID string) ([]*Session, error) {
+	sessions, err := models.GetActiveSessions(authID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, &Session{Session: s, Current: s.FamilyID == currentID})
+	}
+
+	return list, nil
+}
+
+// EndSession sign the user out of one session: its refresh tokens stop
+// working and its access tokens are denied until they expire
+func EndSession(authID, id int) error {
+	session, err := models.GetSession(id)
+	if err != nil {
+		return err
+	}
+	if session.ID == 0 || session.AuthID != authID || session.RevokedOn != 0 {
+		return ErrSessionNotFound
+	}
+
+	return endSession(session.FamilyID)
+}
+
+// TouchSession record that the session was used from ip, at most once per
+// sessionTouchInterval for each session
+func TouchSession(sessionID, ip string) error {
+	now := time.Now()
+
+	sessionTouchMu.Lock()
+	if last, ok := sessionTouched[sessionID]; ok && now.Sub(last) < sessionTouchInterval {
+		sessionTouchMu.Unlock()
+		return nil
+	}
+	sessionTouched[sessionID] = now
+	sessionTouchMu.Unlock()
+
+	return models.TouchSession(sessionID, ip, int(now.Unix()))
+}
+
+// RunSessionTouchPrune forget the sessions TouchSession last wrote more than
+// sessionTouchInterval ago, every sessionTouchInterval until stop is closed
+func RunSessionTouchPrune(stop <-chan struct{}) {
+	ticker := time.NewTicker(sessionTouchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			pruneSessionTouched(now)
+		}
+	}
+}
+
+func pruneSessionTouched(now time.Time) {
+	sessionTouchMu.Lock()
+	defer sessionTouchMu.Unlock()
+
+	for id, last := range sessionTouched {
+		if now.Sub(last) >= sessionTouchInterval {
+			delete(sessionTouched, id)
+		}
+	}
+}
+
+func startSession(authID int, familyID string, client Client) error {
+	userAgent := client.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	return models.AddSession(authID, familyID, userAgent, client.IP, 0)
+}
+
+func endSession(familyID string) error {
+	if err := models.RevokeRefreshTokenFamily(familyID); err != nil {
+		return err
+	}
+	if err := models.RevokeSession(familyID); err != nil {
+		return err
+	}
+
+	return util.RevokeSession(familyID)
+}
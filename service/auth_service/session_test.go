@@ -0,0 +1,23 @@
+package auth_service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneSessionTouched(t *testing.T) {
+	now := time.Now()
+	sessionTouched = map[string]time.Time{
+		"recent": now.Add(-sessionTouchInterval / 2),
+		"stale":  now.Add(-sessionTouchInterval),
+	}
+
+	pruneSessionTouched(now)
+
+	if _, ok := sessionTouched["recent"]; !ok {
+		t.Error("pruneSessionTouched() dropped a session touched within the interval")
+	}
+	if _, ok := sessionTouched["stale"]; ok {
+		t.Error("pruneSessionTouched() kept a session touched an interval ago")
+	}
+}
@@ -0,0 +1,58 @@
+package auth_service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+var (
+	ErrSignatureInvalid  = errors.New("request signature is invalid")
+	ErrSignatureExpired  = errors.New("request timestamp is outside the allowed clock skew")
+	ErrSignatureReplayed = errors.New("request nonce was already used")
+)
+
+// AuthenticateSignature check an HMAC signed request and return the service
+// identity of its key. The nonce is only remembered once the signature is
+// valid, so unsigned requests can't fill the nonce cache.
+func AuthenticateSignature(credentials, method, requestURI string, body []byte) (*common.Identity, error) {
+	header, err := util.ParseSignatureHeader(credentials)
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+
+	key, ok := settings.HmacSettings[header.KeyID]
+	if !ok || key.Secret == "" {
+		return nil, ErrSignatureInvalid
+	}
+
+	skew := settings.AppSetting.HmacClockSkew
+	signedAt := time.Unix(header.Timestamp, 0)
+	if d := time.Since(signedAt); d > skew || d < -skew {
+		return nil, ErrSignatureExpired
+	}
+
+	stringToSign := util.StringToSign(header.Timestamp, header.Nonce, method, requestURI, body)
+	if !util.VerifySignature(key.Secret, stringToSign, header.Signature) {
+		return nil, ErrSignatureInvalid
+	}
+
+	// a nonce only needs to be kept while its timestamp is acceptable
+	fresh, err := util.UseNonce(header.KeyID+":"+header.Nonce, 2*skew)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrSignatureReplayed
+	}
+
+	return &common.Identity{
+		Username:     "hmac:" + header.KeyID,
+		Role:         key.Role,
+		ServiceKeyID: header.KeyID,
+		Scopes:       key.Scopes,
+	}, nil
+}
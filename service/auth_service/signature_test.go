@@ -0,0 +1,134 @@
+package auth_service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+const testSignatureSkew = 5 * time.Minute
+
+func setupSignature() {
+	settings.AppSetting.HmacClockSkew = testSignatureSkew
+	settings.AppSetting.HmacNonceStore = "memory"
+	settings.HmacSettings = map[string]*settings.Hmac{
+		"importer": {Secret: "secret", Role: "editor", Scopes: []string{"article:write"}},
+		"other":    {Secret: "other-secret", Role: "reader"},
+	}
+	util.Setup()
+}
+
+// signCredentials sign the request like util.SignRequest, with the timestamp
+// and nonce given
+func signCredentials(keyID, secret string, timestamp time.Time, nonce, method, requestURI, body string) string {
+	stringToSign := util.StringToSign(timestamp.Unix(), nonce, method, requestURI, []byte(body))
+	return fmt.Sprintf("KeyId=%s,Timestamp=%d,Nonce=%s,Signature=%s",
+		keyID, timestamp.Unix(), nonce, util.ComputeSignature(secret, stringToSign))
+}
+
+func TestAuthenticateSignature(t *testing.T) {
+	setupSignature()
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		credentials string
+		method      string
+		requestURI  string
+		body        string
+		wantErr     error
+	}{
+		{
+			name:        "valid",
+			credentials: signCredentials("importer", "secret", now, "n1", "POST", "/api/v1/articles?a=1", "body"),
+		},
+		{
+			name:        "signed within the clock skew",
+			credentials: signCredentials("importer", "secret", now.Add(-testSignatureSkew+time.Minute), "n2", "POST", "/api/v1/articles?a=1", "body"),
+		},
+		{
+			name:        "clock ahead within the skew",
+			credentials: signCredentials("importer", "secret", now.Add(testSignatureSkew-time.Minute), "n3", "POST", "/api/v1/articles?a=1", "body"),
+		},
+		{
+			name:        "signed too long ago",
+			credentials: signCredentials("importer", "secret", now.Add(-testSignatureSkew-time.Minute), "n4", "POST", "/api/v1/articles?a=1", "body"),
+			wantErr:     ErrSignatureExpired,
+		},
+		{
+			name:        "signed in the future",
+			credentials: signCredentials("importer", "secret", now.Add(testSignatureSkew+time.Minute), "n5", "POST", "/api/v1/articles?a=1", "body"),
+			wantErr:     ErrSignatureExpired,
+		},
+		{
+			name:        "unknown key",
+			credentials: signCredentials("unknown", "secret", now, "n6", "POST", "/api/v1/articles?a=1", "body"),
+			wantErr:     ErrSignatureInvalid,
+		},
+		{
+			name:        "secret of another key",
+			credentials: signCredentials("importer", "other-secret", now, "n7", "POST", "/api/v1/articles?a=1", "body"),
+			wantErr:     ErrSignatureInvalid,
+		},
+		{
+			name:        "body changed",
+			credentials: signCredentials("importer", "secret", now, "n8", "POST", "/api/v1/articles?a=1", "other body"),
+			wantErr:     ErrSignatureInvalid,
+		},
+		{
+			name:        "query changed",
+			credentials: signCredentials("importer", "secret", now, "n9", "POST", "/api/v1/articles?a=2", "body"),
+			wantErr:     ErrSignatureInvalid,
+		},
+		{
+			name:        "method changed",
+			credentials: signCredentials("importer", "secret", now, "n10", "PUT", "/api/v1/articles?a=1", "body"),
+			wantErr:     ErrSignatureInvalid,
+		},
+		{
+			name:        "malformed header",
+			credentials: "KeyId=importer",
+			wantErr:     ErrSignatureInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := AuthenticateSignature(tt.credentials, "POST", "/api/v1/articles?a=1", []byte("body"))
+			if err != tt.wantErr {
+				t.Fatalf("AuthenticateSignature() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (identity.ServiceKeyID != "importer" || identity.Role != "editor" || !identity.HasScope("article:write")) {
+				t.Errorf("AuthenticateSignature() = %+v, want the importer key identity", identity)
+			}
+		})
+	}
+}
+
+func TestAuthenticateSignatureReplay(t *testing.T) {
+	setupSignature()
+	now := time.Now()
+	credentials := signCredentials("importer", "secret", now, "nonce", "GET", "/api/v1/tags", "")
+
+	// a request with a bad signature doesn't use up the nonce
+	forged := signCredentials("importer", "wrong", now, "nonce", "GET", "/api/v1/tags", "")
+	if _, err := AuthenticateSignature(forged, "GET", "/api/v1/tags", nil); err != ErrSignatureInvalid {
+		t.Fatalf("AuthenticateSignature() of a forged request error = %v, want %v", err, ErrSignatureInvalid)
+	}
+
+	if _, err := AuthenticateSignature(credentials, "GET", "/api/v1/tags", nil); err != nil {
+		t.Fatalf("AuthenticateSignature() error = %v", err)
+	}
+	if _, err := AuthenticateSignature(credentials, "GET", "/api/v1/tags", nil); err != ErrSignatureReplayed {
+		t.Errorf("AuthenticateSignature() of a replay error = %v, want %v", err, ErrSignatureReplayed)
+	}
+
+	// nonces are per key
+	other := signCredentials("other", "other-secret", now, "nonce", "GET", "/api/v1/tags", "")
+	if _, err := AuthenticateSignature(other, "GET", "/api/v1/tags", nil); err != nil {
+		t.Errorf("AuthenticateSignature() with the nonce of another key error = %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package auth_service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// IssueTokens start a session for the checked user on a.Client and issue
+// an access token together with the first refresh token of its token family
+func (a *Auth) IssueTokens() (*Tokens, error) {
+	familyID, err := util.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	if err := startSession(a.ID, familyID, a.Client); err != nil {
+		return nil, err
+	}
+
+	return issueTokens(&models.Auth{ID: a.ID, Username: a.Username, Role: a.Role}, familyID)
+}
+
+// Refresh exchange a refresh token for a new access/refresh pair. Presenting
+// a refresh token that was already rotated revokes its whole family.
+func Refresh(refreshToken string) (*Tokens, error) {
+	token, err := models.GetRefreshTokenByHash(util.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if token.ID == 0 || token.RevokedOn != 0 || token.ExpiresOn < int(time.Now().Unix()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if token.RotatedOn != 0 {
+		return nil, revokeReusedFamily(token)
+	}
+
+	rotated, err := models.RotateRefreshToken(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, revokeReusedFamily(token)
+	}
+
+	auth, err := models.GetAuth(token.AuthID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.ID == 0 || auth.State != models.AUTH_STATE_ACTIVE {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	return issueTokens(auth, token.FamilyID)
+}
+
+// Logout revoke the access token of identity and end its session. The
+// session of refreshToken is ended too when given.
+func Logout(identity *common.Identity, refreshToken string) error {
+	if err := util.RevokeToken(identity.TokenID, identity.ExpiresAt); err != nil {
+		return err
+	}
+	if identity.SessionID != "" {
+		if err := endSession(identity.SessionID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := models.GetRefreshTokenByHash(util.HashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if token.ID == 0 || token.AuthID != identity.ID || token.FamilyID == identity.SessionID {
+		return nil
+	}
+
+	return endSession(token.FamilyID)
+}
+
+// RevokeUserTokens revoke every access and refresh token issued to the user,
+// e.g. after a password change or when an account is compromised, and with
+// apiKeys its API keys as well
+func RevokeUserTokens(authID int, apiKeys bool) error {
+	now := time.Now()
+	if apiKeys {
+		if err := models.RevokeApiKeysByAuth(authID); err != nil {
+			return err
+		}
+	}
+	if err := models.RevokeAuthTokensAt(authID, unixMilli(now)); err != nil {
+		return err
+	}
+	if err := util.RevokeUserTokens(authID, now); err != nil {
+		return err
+	}
+	if err := models.RevokeSessionsByAuth(authID); err != nil {
+		return err
+	}
+
+	return models.RevokeRefreshTokensByAuth(authID)
+}
+
+func revokeReusedFamily(token *models.RefreshToken) error {
+	logging.Warn("auth_service.Refresh refresh token reused, revoking family", token.AuthID, token.FamilyID)
+	if err := endSession(token.FamilyID); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func issueTokens(auth *models.Auth, familyID string) (*Tokens, error) {
+	accessToken, err := util.GenerateToken(auth.ID, auth.Username, auth.Role, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := util.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresOn := time.Now().Add(settings.AppSetting.RefreshTokenExpire).Unix()
+	if err := models.AddRefreshToken(auth.ID, familyID, util.HashToken(refreshToken), int(expiresOn)); err != nil {
+		return nil, err
+	}
+	if err := models.ExtendSession(familyID, int(expiresOn)); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(settings.AppSetting.JwtExpire.Seconds()),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package auth_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/miaozhang/webservice/internal/testdb"
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/settings"
+	"github.com/miaozhang/webservice/util"
+)
+
+// useTestDB run the models on an empty in-memory database with the tables
+// of values, and reset the stores of util
+func useTestDB(t *testing.T, values ...interface{}) *gorm.DB {
+	conn := testdb.Open(t, models.UseDB, values...)
+
+	settings.AppSetting.JwtSecret = "auth-service-test-secret"
+	settings.AppSetting.JwtIssuer = "webservice"
+	settings.AppSetting.JwtAudience = "webservice"
+	settings.AppSetting.JwtSigningMethod = ""
+	settings.AppSetting.JwtExpire = time.Hour
+	settings.AppSetting.RefreshTokenExpire = 24 * time.Hour
+	settings.AppSetting.PasswordHashCost = 4
+	settings.AppSetting.TokenDenylist = "memory"
+	util.Setup()
+
+	return conn
+}
+
+func TestRefreshReuse(t *testing.T) {
+	useTestDB(t, &models.Auth{}, &models.RefreshToken{}, &models.Session{})
+	if err := models.AddAuth("alice", "", "", models.ROLE_READER); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := models.GetAuthByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := &Auth{ID: auth.ID, Username: auth.Username, Role: auth.Role}
+
+	first, err := login.IssueTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := login.IssueTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	claims, err := util.ParseToken(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := util.IsTokenRevoked(claims); revoked {
+		t.Fatal("access token of the rotated family is revoked before any reuse")
+	}
+
+	// the rotated token comes back, e.g. stolen before the owner refreshed
+	if _, err := Refresh(first.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("Refresh() of a rotated token error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	// the reuse ends the whole family: its latest refresh and access tokens
+	if _, err := Refresh(second.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Errorf("Refresh() of the family after reuse error = %v, want %v", err, ErrRefreshTokenInvalid)
+	}
+	if revoked, _ := util.IsTokenRevoked(claims); !revoked {
+		t.Error("access token of the family is still accepted after reuse")
+	}
+
+	// other sessions of the user go on
+	if _, err := Refresh(other.RefreshToken); err != nil {
+		t.Errorf("Refresh() of another session error = %v", err)
+	}
+	if _, err := Refresh("unknown"); err != ErrRefreshTokenInvalid {
+		t.Errorf("Refresh() of an unknown token error = %v, want %v", err, ErrRefreshTokenInvalid)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	tests := []struct {
+		name        string
+		apiKeys     bool
+		wantApiKeys int
+	}{
+		{name: "tokens only", apiKeys: false, wantApiKeys: 1},
+		{name: "tokens and API keys", apiKeys: true, wantApiKeys: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t, &models.Auth{}, &models.RefreshToken{}, &models.Session{}, &models.ApiKey{})
+			if err := models.AddAuth("alice", "", "", models.ROLE_READER); err != nil {
+				t.Fatal(err)
+			}
+			auth, err := models.GetAuthByUsername("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expiresOn := int(time.Now().Add(time.Hour).Unix())
+			if err := models.AddApiKey(auth.ID, "ci", "abcd", "hash", "", expiresOn); err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := (&Auth{ID: auth.ID, Username: auth.Username, Role: auth.Role}).IssueTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := RevokeUserTokens(auth.ID, tt.apiKeys); err != nil {
+				t.Fatalf("RevokeUserTokens() error = %v", err)
+			}
+
+			if _, err := Refresh(tokens.RefreshToken); err == nil {
+				t.Error("Refresh() with a revoked refresh token succeeded")
+			}
+			keys, err := models.GetApiKeys(auth.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != tt.wantApiKeys {
+				t.Errorf("RevokeUserTokens() left %d API keys, want %d", len(keys), tt.wantApiKeys)
+			}
+		})
+	}
+}
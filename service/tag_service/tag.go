@@ -5,6 +5,7 @@ import "github.com/miaozhang/webservice/models"
 type Tag struct {
 	ID         int
 	Name       string
+	Slug       string
 	CreatedBy  string
 	ModifiedBy string
 	State      int
@@ -21,6 +22,29 @@ func (t *Tag) ExistByID() (bool, error) {
 	return models.ExistTagByID(t.ID)
 }
 
+// GetBySlug return the tag having t.Slug, or the current slug of the tag
+// that formerly had it to redirect to. Both are empty when no tag ever had it.
+func (t *Tag) GetBySlug() (*models.Tag, string, error) {
+	tag, err := models.GetTagBySlug(t.Slug)
+	if err != nil {
+		return nil, "", err
+	}
+	if tag.ID > 0 {
+		return tag, "", nil
+	}
+
+	id, err := models.GetSlugRedirect(models.SLUG_KIND_TAG, t.Slug)
+	if err != nil || id == 0 {
+		return nil, "", err
+	}
+	tag, err = models.GetTag(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nil, tag.Slug, nil
+}
+
 func (t *Tag) Add() error {
 	return models.AddTag(t.Name, t.State, t.CreatedBy)
 }
@@ -0,0 +1,138 @@
+package user_service
+
+import (
+	"github.com/miaozhang/webservice/models"
+	"github.com/miaozhang/webservice/service/auth_service"
+	"github.com/miaozhang/webservice/util"
+)
+
+type User struct {
+	ID       int
+	Username string
+	Password string
+	Email    string
+	Role     string
+	State    int
+
+	PageNum  int
+	PageSize int
+}
+
+func (u *User) ExistByID() (bool, error) {
+	return models.ExistAuthByID(u.ID)
+}
+
+func (u *User) ExistByUsername() (bool, error) {
+	return models.ExistAuthByUsername(u.Username)
+}
+
+// UsernameTaken report whether another user already has u.Username
+func (u *User) UsernameTaken() (bool, error) {
+	auth, err := models.GetAuthByUsername(u.Username)
+	if err != nil {
+		return false, err
+	}
+
+	return auth.ID > 0 && auth.ID != u.ID, nil
+}
+
+func (u *User) Add() error {
+	hashed, err := util.HashPassword(u.Password)
+	if err != nil {
+		return err
+	}
+
+	return models.AddAuth(u.Username, hashed, u.Email, u.Role)
+}
+
+// Edit update the username, email, role and, unless it is -1, the state. The
+// user's tokens are revoked so a changed role or a disabled account takes
+// effect immediately.
+func (u *User) Edit() error {
+	data := make(map[string]interface{})
+	data["username"] = u.Username
+	data["email"] = u.Email
+	data["role"] = u.Role
+	if u.State >= 0 {
+		data["state"] = u.State
+	}
+
+	if err := models.EditAuth(u.ID, data); err != nil {
+		return err
+	}
+
+	return auth_service.RevokeUserTokens(u.ID, u.State == models.AUTH_STATE_DISABLED)
+}
+
+// Disable block the user from logging in and revoke every token and API key
+// already issued
+func (u *User) Disable() error {
+	if err := models.EditAuth(u.ID, map[string]interface{}{"state": models.AUTH_STATE_DISABLED}); err != nil {
+		return err
+	}
+
+	return auth_service.RevokeUserTokens(u.ID, true)
+}
+
+// ResetPassword set a new password and revoke every token and API key issued
+// with the old one
+func (u *User) ResetPassword() error {
+	hashed, err := util.HashPassword(u.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := models.UpdateAuthPassword(u.ID, hashed); err != nil {
+		return err
+	}
+
+	return auth_service.RevokeUserTokens(u.ID, true)
+}
+
+func (u *User) RevokeTokens() error {
+	return auth_service.RevokeUserTokens(u.ID, true)
+}
+
+func (u *User) Delete() error {
+	if err := auth_service.RevokeUserTokens(u.ID, true); err != nil {
+		return err
+	}
+	if err := models.DeleteAuthIdentities(u.ID); err != nil {
+		return err
+	}
+
+	return models.DeleteAuth(u.ID)
+}
+
+func (u *User) Get() (*models.Auth, error) {
+	return models.GetAuth(u.ID)
+}
+
+func (u *User) GetAll() ([]*models.Auth, error) {
+	users, err := models.GetAuthList(u.PageNum, u.PageSize, u.getMaps())
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (u *User) Count() (int, error) {
+	return models.GetAuthTotal(u.getMaps())
+}
+
+func (u *User) getMaps() map[string]interface{} {
+	maps := make(map[string]interface{})
+
+	if u.Username != "" {
+		maps["username"] = u.Username
+	}
+	if u.Role != "" {
+		maps["role"] = u.Role
+	}
+	if u.State >= 0 {
+		maps["state"] = u.State
+	}
+
+	return maps
+}
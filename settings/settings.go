@@ -2,15 +2,54 @@ package settings
 
 import (
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-ini/ini"
 )
 
 type App struct {
-	JwtSecret string
-	PageSize  int
-	PrefixUrl string
+	JwtSecret   string
+	JwtExpire   time.Duration
+	JwtIssuer   string
+	JwtAudience string
+
+	JwtCookieName string
+	JwtQueryToken bool
+
+	JwtSigningMethod string
+	JwtKeySavePath   string
+	JwtKeyRotation   time.Duration
+
+	RefreshTokenExpire time.Duration
+	MfaTokenExpire     time.Duration
+	TokenDenylist      string
+
+	PasswordResetExpire time.Duration
+	PasswordResetUrl    string
+
+	LegacyAuth       bool
+	LegacyAuthSunset time.Time
+
+	LoginMaxAttempts   int
+	LoginIPMaxAttempts int
+	LoginBackoff       time.Duration
+	LoginLockout       time.Duration
+	LoginAttemptStore  string
+
+	HmacClockSkew  time.Duration
+	HmacNonceStore string
+
+	PageSize   int
+	PrefixUrl  string
+	ArticleUrl string
+
+	SearchIndex   string
+	MarkdownCache string
+
+	PublishInterval time.Duration
+
+	PasswordHashCost int
 
 	RuntimeRootPath string
 
@@ -21,6 +60,7 @@ type App struct {
 	ExportSavePath string
 	QrCodeSavePath string
 	FontSavePath   string
+	PosterFont     string
 
 	LogSavePath string
 	LogSaveName string
@@ -60,6 +100,42 @@ type Redis struct {
 
 var RedisSetting = &Redis{}
 
+type Mail struct {
+	Type     string
+	Host     string
+	User     string
+	Password string
+	From     string
+	FilePath string
+}
+
+var MailSetting = &Mail{}
+
+// Oidc configure one OpenID Connect provider, read from an [oidc.<name>] section
+type Oidc struct {
+	Issuer        string
+	ClientId      string
+	ClientSecret  string
+	RedirectUrl   string
+	Scopes        []string
+	UsernameClaim string
+	Role          string
+}
+
+// Hmac configure one shared key for signed service-to-service requests,
+// read from an [hmac.<key id>] section
+type Hmac struct {
+	Secret string
+	Role   string
+	Scopes []string
+}
+
+// HmacSettings hold the request signing keys by key id
+var HmacSettings = map[string]*Hmac{}
+
+// OidcSettings hold the configured providers by name
+var OidcSettings = map[string]*Oidc{}
+
 var cfg *ini.File
 
 // Setup initialize the configuration instance
@@ -74,7 +150,27 @@ func Setup() {
 	mapTo("server", ServerSetting)
 	mapTo("database", DatabaseSetting)
 	mapTo("redis", RedisSetting)
+	mapTo("mail", MailSetting)
+	for _, section := range cfg.Section("oidc").ChildSections() {
+		provider := &Oidc{Scopes: []string{"openid", "profile", "email"}, UsernameClaim: "preferred_username", Role: "reader"}
+		mapTo(section.Name(), provider)
+		OidcSettings[strings.TrimPrefix(section.Name(), "oidc.")] = provider
+	}
+	for _, section := range cfg.Section("hmac").ChildSections() {
+		key := &Hmac{}
+		mapTo(section.Name(), key)
+		HmacSettings[strings.TrimPrefix(section.Name(), "hmac.")] = key
+	}
 
+	AppSetting.JwtExpire = AppSetting.JwtExpire * time.Minute
+	AppSetting.JwtKeyRotation = AppSetting.JwtKeyRotation * time.Hour
+	AppSetting.RefreshTokenExpire = AppSetting.RefreshTokenExpire * time.Hour
+	AppSetting.MfaTokenExpire = AppSetting.MfaTokenExpire * time.Minute
+	AppSetting.PasswordResetExpire = AppSetting.PasswordResetExpire * time.Minute
+	AppSetting.LoginBackoff = AppSetting.LoginBackoff * time.Second
+	AppSetting.LoginLockout = AppSetting.LoginLockout * time.Minute
+	AppSetting.HmacClockSkew = AppSetting.HmacClockSkew * time.Second
+	AppSetting.PublishInterval = AppSetting.PublishInterval * time.Second
 	AppSetting.ImageMaxSize = AppSetting.ImageMaxSize * 1024 * 1024
 	ServerSetting.ReadTimeout = ServerSetting.ReadTimeout * time.Second
 	ServerSetting.WriteTimeout = ServerSetting.WriteTimeout * time.Second
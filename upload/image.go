@@ -0,0 +1,130 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// imageTypes map the image extensions to the MIME type sniffed from their content
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+}
+
+// GetImageFullUrl get the public url of the image saved under name
+func GetImageFullUrl(name string) string {
+	return settings.AppSetting.PrefixUrl + "/" + GetImagePath() + name
+}
+
+// GetImageName get the name an image is saved under: the sha256 of its
+// content, fanned out over two levels of directories, e.g. 3f/a2/3fa2...9c.png
+func GetImageName(content []byte, fileName string) string {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	return path.Join(hash[0:2], hash[2:4], hash+strings.ToLower(logging.GetExt(fileName)))
+}
+
+// GetImagePath get the save path of the images, relative to RuntimeRootPath
+func GetImagePath() string {
+	return settings.AppSetting.ImageSavePath
+}
+
+// GetImageFullPath get the directory the images are saved in
+func GetImageFullPath() string {
+	return settings.AppSetting.RuntimeRootPath + GetImagePath()
+}
+
+// CheckImageExt check the extension of the file name is in ImageAllowExts
+func CheckImageExt(fileName string) bool {
+	ext := logging.GetExt(fileName)
+	for _, allowExt := range settings.AppSetting.ImageAllowExts {
+		if strings.ToUpper(allowExt) == strings.ToUpper(ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckImageSize check the file is not larger than ImageMaxSize
+func CheckImageSize(f multipart.File) bool {
+	size, err := logging.GetSize(f)
+	if err != nil {
+		logging.Warn(err)
+		return false
+	}
+
+	return size <= settings.AppSetting.ImageMaxSize
+}
+
+// CheckImageType check the content sniffed is an image of the type its
+// extension claims
+func CheckImageType(content []byte, fileName string) bool {
+	want, ok := imageTypes[strings.ToLower(logging.GetExt(fileName))]
+	if !ok {
+		return false
+	}
+
+	return http.DetectContentType(content) == want
+}
+
+// CheckImage create the directory src if needed and check it can be written
+func CheckImage(src string) error {
+	err := logging.IsNotExistMkDir(src)
+	if err != nil {
+		return fmt.Errorf("file.IsNotExistMkDir err: %v", err)
+	}
+
+	if logging.CheckPermission(src) {
+		return fmt.Errorf("file.CheckPermission Permission denied src: %s", src)
+	}
+
+	return nil
+}
+
+// SaveImage write the content under name in the image directory. An image
+// with the same name has the same content, so it is kept as is.
+func SaveImage(content []byte, name string) error {
+	fullPath := GetImageFullPath() + name
+	if err := CheckImage(path.Dir(fullPath)); err != nil {
+		return err
+	}
+	if !logging.CheckNotExist(fullPath) {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(path.Dir(fullPath), ".upload-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fullPath)
+}
@@ -0,0 +1,112 @@
+package upload
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+// encodeImage return a small image encoded by encode
+func encodeImage(t *testing.T, encode func(*bytes.Buffer, image.Image) error) []byte {
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCheckImageType(t *testing.T) {
+	pngContent := encodeImage(t, func(b *bytes.Buffer, m image.Image) error { return png.Encode(b, m) })
+	jpegContent := encodeImage(t, func(b *bytes.Buffer, m image.Image) error { return jpeg.Encode(b, m, nil) })
+	gifContent := encodeImage(t, func(b *bytes.Buffer, m image.Image) error { return gif.Encode(b, m, nil) })
+	bmpContent := encodeImage(t, func(b *bytes.Buffer, m image.Image) error { return bmp.Encode(b, m) })
+	webpContent := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+	htmlContent := []byte("<html><body><script>alert(1)</script></body></html>")
+
+	tests := []struct {
+		name     string
+		content  []byte
+		fileName string
+		want     bool
+	}{
+		{"png", pngContent, "cover.png", true},
+		{"upper case extension", pngContent, "COVER.PNG", true},
+		{"jpg", jpegContent, "cover.jpg", true},
+		{"jpeg", jpegContent, "cover.jpeg", true},
+		{"gif", gifContent, "cover.gif", true},
+		{"bmp", bmpContent, "cover.bmp", true},
+		{"webp", webpContent, "cover.webp", true},
+		{"png named jpg", pngContent, "cover.jpg", false},
+		{"jpeg named png", jpegContent, "cover.png", false},
+		{"html named png", htmlContent, "cover.png", false},
+		{"unknown extension", pngContent, "cover.svg", false},
+		{"no extension", pngContent, "cover", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckImageType(tt.content, tt.fileName); got != tt.want {
+				t.Errorf("CheckImageType(%s) = %v, want %v", tt.fileName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetImageName(t *testing.T) {
+	content := []byte("image content")
+
+	name := GetImageName(content, "Cover.PNG")
+	if !strings.HasSuffix(name, ".png") || strings.Count(name, "/") != 2 {
+		t.Errorf("GetImageName() = %q, want <2 hex>/<2 hex>/<sha256>.png", name)
+	}
+	if other := GetImageName(content, "other.png"); other != name {
+		t.Errorf("GetImageName() of the same content = %q, want %q", other, name)
+	}
+	if other := GetImageName([]byte("other content"), "Cover.PNG"); other == name {
+		t.Errorf("GetImageName() of other content = %q, the same name", other)
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings.AppSetting.RuntimeRootPath = dir + "/"
+	settings.AppSetting.ImageSavePath = "upload/images/"
+
+	content := []byte("image content")
+	name := GetImageName(content, "cover.png")
+	for i := 0; i < 2; i++ {
+		if err := SaveImage(content, name); err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+	}
+
+	saved, err := ioutil.ReadFile(GetImageFullPath() + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, content) {
+		t.Errorf("SaveImage() wrote %q, want %q", saved, content)
+	}
+	files, err := ioutil.ReadDir(GetImageFullPath() + name[:6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("SaveImage() twice left %d files, want 1", len(files))
+	}
+}
@@ -0,0 +1,139 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/gredis"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// AttemptCounter count failed attempts per key (a username, a client IP...)
+// and keeps keys blocked for a while
+type AttemptCounter interface {
+	// Fail record a failed attempt and return the failures within ttl of each other
+	Fail(key string, ttl time.Duration) (int, error)
+	// Block refuse further attempts for key during d
+	Block(key string, d time.Duration) error
+	// BlockedFor return how long key is still blocked, 0 if it is not
+	BlockedFor(key string) (time.Duration, error)
+	// Reset forget the failures and block of key
+	Reset(key string) error
+}
+
+type memoryAttempt struct {
+	failures     int
+	expires      time.Time
+	blockedUntil time.Time
+}
+
+type MemoryAttemptCounter struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryAttempt
+}
+
+func NewMemoryAttemptCounter() *MemoryAttemptCounter {
+	return &MemoryAttemptCounter{attempts: make(map[string]*memoryAttempt)}
+}
+
+func (m *MemoryAttemptCounter) Fail(key string, ttl time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, a := range m.attempts {
+		if a.expires.Before(now) && a.blockedUntil.Before(now) {
+			delete(m.attempts, k)
+		}
+	}
+
+	a, ok := m.attempts[key]
+	if !ok {
+		a = &memoryAttempt{}
+		m.attempts[key] = a
+	}
+	if a.expires.Before(now) {
+		a.failures = 0
+	}
+	a.failures++
+	a.expires = now.Add(ttl)
+
+	return a.failures, nil
+}
+
+func (m *MemoryAttemptCounter) Block(key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.attempts[key]
+	if !ok {
+		a = &memoryAttempt{}
+		m.attempts[key] = a
+	}
+	a.blockedUntil = time.Now().Add(d)
+
+	return nil
+}
+
+func (m *MemoryAttemptCounter) BlockedFor(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.attempts[key]
+	if !ok {
+		return 0, nil
+	}
+
+	if d := time.Until(a.blockedUntil); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryAttemptCounter) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
+
+// RedisAttemptCounter share the counters between every instance through Redis
+type RedisAttemptCounter struct{}
+
+func (r *RedisAttemptCounter) Fail(key string, ttl time.Duration) (int, error) {
+	return gredis.Incr(cacheKey(common.CACHE_LOGIN_ATTEMPTS, key), ttl)
+}
+
+func (r *RedisAttemptCounter) Block(key string, d time.Duration) error {
+	return gredis.Set(cacheKey(common.CACHE_LOGIN_BLOCKED, key), "1", d)
+}
+
+func (r *RedisAttemptCounter) BlockedFor(key string) (time.Duration, error) {
+	return gredis.TTL(cacheKey(common.CACHE_LOGIN_BLOCKED, key))
+}
+
+func (r *RedisAttemptCounter) Reset(key string) error {
+	if err := gredis.Delete(cacheKey(common.CACHE_LOGIN_ATTEMPTS, key)); err != nil {
+		return err
+	}
+
+	return gredis.Delete(cacheKey(common.CACHE_LOGIN_BLOCKED, key))
+}
+
+var attemptCounter AttemptCounter = NewMemoryAttemptCounter()
+
+func setupAttemptCounter() {
+	switch settings.AppSetting.LoginAttemptStore {
+	case "redis":
+		attemptCounter = &RedisAttemptCounter{}
+	default:
+		attemptCounter = NewMemoryAttemptCounter()
+	}
+}
+
+// Attempts return the configured AttemptCounter
+func Attempts() AttemptCounter {
+	return attemptCounter
+}
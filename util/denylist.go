@@ -0,0 +1,187 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/gredis"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// TokenDenylist keeps track of access tokens that were revoked before they expired
+type TokenDenylist interface {
+	// Revoke deny a single token id until the token would have expired anyway
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+	// RevokeUser deny every token issued to the user before at
+	RevokeUser(userID int, at time.Time) error
+	// UserRevokedAt return the latest RevokeUser time in unix milliseconds, 0
+	// if none is in effect
+	UserRevokedAt(userID int) (int64, error)
+}
+
+type MemoryDenylist struct {
+	mu    sync.Mutex
+	jtis  map[string]time.Time
+	users map[int]time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{
+		jtis:  make(map[string]time.Time),
+		users: make(map[int]time.Time),
+	}
+}
+
+func (d *MemoryDenylist) Revoke(jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range d.jtis {
+		if exp.Before(now) {
+			delete(d.jtis, k)
+		}
+	}
+	d.jtis[jti] = expiresAt
+
+	return nil
+}
+
+func (d *MemoryDenylist) IsRevoked(jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	exp, ok := d.jtis[jti]
+	return ok && exp.After(time.Now()), nil
+}
+
+func (d *MemoryDenylist) RevokeUser(userID int, at time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if at.After(d.users[userID]) {
+		d.users[userID] = at
+	}
+	return nil
+}
+
+func (d *MemoryDenylist) UserRevokedAt(userID int) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	revokedAt, ok := d.users[userID]
+	if !ok || revokedAt.Add(settings.AppSetting.JwtExpire).Before(time.Now()) {
+		return 0, nil
+	}
+
+	return unixMilli(revokedAt), nil
+}
+
+// RedisDenylist share revocations between every instance through Redis,
+// entries expire together with the tokens they deny
+type RedisDenylist struct{}
+
+func (d *RedisDenylist) Revoke(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return gredis.Set(cacheKey(common.CACHE_TOKEN_DENYLIST, jti), "1", ttl)
+}
+
+func (d *RedisDenylist) IsRevoked(jti string) (bool, error) {
+	return gredis.Exists(cacheKey(common.CACHE_TOKEN_DENYLIST, jti))
+}
+
+func (d *RedisDenylist) RevokeUser(userID int, at time.Time) error {
+	ttl := time.Until(at.Add(settings.AppSetting.JwtExpire))
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := cacheKey(common.CACHE_USER_TOKEN_DENYLIST, strconv.Itoa(userID))
+	return gredis.Set(key, strconv.FormatInt(unixMilli(at), 10), ttl)
+}
+
+func (d *RedisDenylist) UserRevokedAt(userID int) (int64, error) {
+	value, ok, err := gredis.Get(cacheKey(common.CACHE_USER_TOKEN_DENYLIST, strconv.Itoa(userID)))
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	revokedAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	// written in seconds before the denylist moved to milliseconds
+	if revokedAt < 1e12 {
+		revokedAt *= 1000
+	}
+
+	return revokedAt, nil
+}
+
+func cacheKey(prefix, id string) string {
+	return strings.Join([]string{prefix, id}, "_")
+}
+
+var tokenDenylist TokenDenylist = NewMemoryDenylist()
+
+func setupTokenDenylist() {
+	switch settings.AppSetting.TokenDenylist {
+	case "redis":
+		tokenDenylist = &RedisDenylist{}
+	default:
+		tokenDenylist = NewMemoryDenylist()
+	}
+}
+
+// RevokeToken deny the token until it expires
+func RevokeToken(jti string, expiresAt int64) error {
+	return tokenDenylist.Revoke(jti, time.Unix(expiresAt, 0))
+}
+
+// RevokeUserTokens deny every token issued to the user before at
+func RevokeUserTokens(userID int, at time.Time) error {
+	return tokenDenylist.RevokeUser(userID, at)
+}
+
+// RevokeSession deny every access token issued within the session
+func RevokeSession(sessionID string) error {
+	return tokenDenylist.Revoke(sessionDenylistID(sessionID), time.Now().Add(settings.AppSetting.JwtExpire))
+}
+
+// IsTokenRevoked check the parsed claims against the denylist
+func IsTokenRevoked(claims *Claims) (bool, error) {
+	revoked, err := tokenDenylist.IsRevoked(claims.Id)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := tokenDenylist.IsRevoked(sessionDenylistID(claims.SessionID))
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	revokedAt, err := tokenDenylist.UserRevokedAt(claims.UserID())
+	if err != nil {
+		return false, err
+	}
+
+	return revokedAt > 0 && claims.issuedAtMilli() < revokedAt, nil
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func sessionDenylistID(sessionID string) string {
+	return "sid:" + sessionID
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+func TestIsTokenRevokedByUser(t *testing.T) {
+	settings.AppSetting.JwtExpire = time.Hour
+	tokenDenylist = NewMemoryDenylist()
+
+	revokedAt := time.Now().Truncate(time.Second).Add(-time.Minute + 500*time.Millisecond)
+	if err := RevokeUserTokens(1, revokedAt); err != nil {
+		t.Fatal(err)
+	}
+	// revocations come back in any order from the database sync
+	if err := RevokeUserTokens(1, revokedAt.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		user        string
+		issuedAt    time.Time
+		legacy      bool
+		wantRevoked bool
+	}{
+		{name: "issued before", user: "1", issuedAt: revokedAt.Add(-time.Millisecond), wantRevoked: true},
+		{name: "issued after in the same second", user: "1", issuedAt: revokedAt.Add(time.Millisecond)},
+		{name: "issued at the revocation", user: "1", issuedAt: revokedAt},
+		{name: "issued the next second", user: "1", issuedAt: revokedAt.Add(time.Second)},
+		{name: "without iat_ms in the same second", user: "1", issuedAt: revokedAt.Add(time.Millisecond), legacy: true, wantRevoked: true},
+		{name: "without iat_ms the next second", user: "1", issuedAt: revokedAt.Add(time.Second), legacy: true},
+		{name: "other user", user: "2", issuedAt: revokedAt.Add(-time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{StandardClaims: jwt.StandardClaims{
+				Id:       tt.name,
+				Subject:  tt.user,
+				IssuedAt: tt.issuedAt.Unix(),
+			}}
+			if !tt.legacy {
+				claims.IssuedAtMilli = unixMilli(tt.issuedAt)
+			}
+
+			revoked, err := IsTokenRevoked(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("IsTokenRevoked() = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", context: 3, want: ""},
+		{name: "both empty", context: 3, want: ""},
+		{
+			name: "from empty", a: "", b: "a\nb\n", context: 3,
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty", a: "a\nb\n", b: "", context: 3,
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "change at the start", a: "a\nb\nc\nd\n", b: "x\nb\nc\nd\n", context: 1,
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-a\n+x\n b\n",
+		},
+		{
+			name: "change at the end without newline", a: "a\nb\nc", b: "a\nb\nd", context: 1,
+			want: "--- a\n+++ b\n@@ -2,2 +2,2 @@\n b\n-c\n\\ No newline at end of file\n+d\n\\ No newline at end of file\n",
+		},
+		{
+			name: "newline added at the end", a: "a\nb", b: "a\nb\n", context: 1,
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "adjacent hunks merge", a: "1\n2\n3\n4\n5\n6\n7\n", b: "1\nx\n3\n4\ny\n6\n7\n", context: 1,
+			want: "--- a\n+++ b\n@@ -1,6 +1,6 @@\n 1\n-2\n+x\n 3\n 4\n-5\n+y\n 6\n",
+		},
+		{
+			name: "distant hunks stay apart", a: "1\n2\n3\n4\n5\n6\n7\n", b: "1\nx\n3\n4\n5\ny\n7\n", context: 1,
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+x\n 3\n@@ -5,3 +5,3 @@\n 5\n-6\n+y\n 7\n",
+		},
+		{
+			name: "insertion in the middle", a: "1\n2\n3\n4\n", b: "1\n2\nx\n3\n4\n", context: 0,
+			want: "--- a\n+++ b\n@@ -2,0 +3 @@\n+x\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("a", "b", tt.a, tt.b, tt.context); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// applyOps return the texts before and after the edit script
+func applyOps(ops []diffOp) (string, string) {
+	var a, b strings.Builder
+	for _, op := range ops {
+		if op.kind != '+' {
+			a.WriteString(op.line)
+		}
+		if op.kind != '-' {
+			b.WriteString(op.line)
+		}
+	}
+
+	return a.String(), b.String()
+}
+
+func countEdits(ops []diffOp) int {
+	edits := 0
+	for _, op := range ops {
+		if op.kind != ' ' {
+			edits++
+		}
+	}
+
+	return edits
+}
+
+func TestDiffLines(t *testing.T) {
+	lines := func(prefix string, n int, every int) string {
+		var s strings.Builder
+		for i := 0; i < n; i++ {
+			if every > 0 && i%every == 0 {
+				fmt.Fprintf(&s, "%s%d\n", prefix, i)
+			} else {
+				fmt.Fprintf(&s, "same%d\n", i)
+			}
+		}
+		return s.String()
+	}
+
+	tests := []struct {
+		name      string
+		a, b      string
+		wantEdits int
+	}{
+		{name: "interleaved changes", a: lines("a", 50, 5), b: lines("b", 50, 5), wantEdits: 20},
+		{name: "no line in common within the edit bound", a: lines("a", 400, 1), b: lines("b", 400, 1), wantEdits: 800},
+		// 1200 edits would do, but past maxDiffEdits the 1199 lines between
+		// the common suffix and the start are removed and added as a whole
+		{name: "past the edit bound", a: lines("a", 1200, 2), b: lines("b", 1200, 2), wantEdits: 2398},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := diffLines(splitLines(tt.a), splitLines(tt.b))
+
+			a, b := applyOps(ops)
+			if a != tt.a || b != tt.b {
+				t.Fatal("diffLines() script doesn't turn a into b")
+			}
+			if edits := countEdits(ops); edits != tt.wantEdits {
+				t.Errorf("diffLines() made %d edits, want %d", edits, tt.wantEdits)
+			}
+		})
+	}
+}
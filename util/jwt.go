@@ -1,6 +1,8 @@
 package util
 
 import (
+	"errors"
+	"strconv"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -8,37 +10,192 @@ import (
 	"github.com/miaozhang/webservice/settings"
 )
 
-var jwtSecret = []byte(settings.AppSetting.JwtSecret)
+var jwtSecret []byte
 
 type Claims struct {
 	Username string `json:"username"`
-	Password string `json:"password"`
+	Role     string `json:"role"`
+	// SessionID is the refresh token family the access token was issued with
+	SessionID string `json:"sid,omitempty"`
+	// IssuedAtMilli is iat in milliseconds, to tell the tokens issued right
+	// after a revocation from those it denies
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
 	jwt.StandardClaims
 }
 
-func GenerateToken(username, password string) (string, error) {
+// UserID return the Auth.ID carried in the subject claim
+func (c *Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// issuedAtMilli return when the token was issued in unix milliseconds. Tokens
+// issued before iat_ms was added count from the start of their second.
+func (c *Claims) issuedAtMilli() int64 {
+	if c.IssuedAtMilli > 0 {
+		return c.IssuedAtMilli
+	}
+
+	return c.IssuedAt * 1000
+}
+
+// Valid check the standard time based claims plus issuer and audience
+func (c Claims) Valid() error {
+	return c.validate(settings.AppSetting.JwtAudience)
+}
+
+func (c Claims) validate(audience string) error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+
+	if !c.VerifyIssuer(settings.AppSetting.JwtIssuer, true) {
+		return jwt.NewValidationError("token has invalid issuer", jwt.ValidationErrorIssuer)
+	}
+	if !c.VerifyAudience(audience, true) {
+		return jwt.NewValidationError("token has invalid audience", jwt.ValidationErrorAudience)
+	}
+	if c.UserID() <= 0 {
+		return jwt.NewValidationError("token has invalid subject", jwt.ValidationErrorClaimsInvalid)
+	}
+
+	return nil
+}
+
+// MfaClaims is carried by the short-lived token that proves the password was
+// checked while the second factor is still pending. Its own audience keeps it
+// from being accepted as an access token.
+type MfaClaims struct {
+	Claims
+}
+
+func (c MfaClaims) Valid() error {
+	return c.validate(mfaAudience())
+}
+
+func mfaAudience() string {
+	return settings.AppSetting.JwtAudience + "/mfa"
+}
+
+// OidcStateClaims carry the provider, nonce and PKCE verifier of an OpenID
+// Connect login between the redirect to the provider and the callback. The
+// jti is the state parameter sent to the provider.
+type OidcStateClaims struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+func (c OidcStateClaims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+
+	if !c.VerifyIssuer(settings.AppSetting.JwtIssuer, true) {
+		return jwt.NewValidationError("token has invalid issuer", jwt.ValidationErrorIssuer)
+	}
+	if !c.VerifyAudience(oidcStateAudience(), true) {
+		return jwt.NewValidationError("token has invalid audience", jwt.ValidationErrorAudience)
+	}
+
+	return nil
+}
+
+func oidcStateAudience() string {
+	return settings.AppSetting.JwtAudience + "/oidc"
+}
+
+func GenerateToken(id int, username, role, sessionID string) (string, error) {
+	claims, err := newClaims(id, username, role, settings.AppSetting.JwtAudience, settings.AppSetting.JwtExpire)
+	if err != nil {
+		return "", err
+	}
+	claims.SessionID = sessionID
+
+	return signToken(claims)
+}
+
+// GenerateMfaToken issue the token that must be exchanged together with a
+// second factor code for a full access token
+func GenerateMfaToken(id int, username, role string) (string, error) {
+	claims, err := newClaims(id, username, role, mfaAudience(), settings.AppSetting.MfaTokenExpire)
+	if err != nil {
+		return "", err
+	}
+
+	return signToken(MfaClaims{*claims})
+}
+
+func newClaims(id int, username, role, audience string, expire time.Duration) (*Claims, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
 	nowTime := time.Now()
-	expireTime := nowTime.Add(3 * time.Hour)
+	expireTime := nowTime.Add(expire)
 
-	claims := Claims{
-		username,
-		password,
-		jwt.StandardClaims{
+	return &Claims{
+		Username:      username,
+		Role:          role,
+		IssuedAtMilli: unixMilli(nowTime),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.Itoa(id),
+			Id:        jti,
+			IssuedAt:  nowTime.Unix(),
+			NotBefore: nowTime.Unix(),
 			ExpiresAt: expireTime.Unix(),
-			Issuer:    "gin-blog",
+			Issuer:    settings.AppSetting.JwtIssuer,
+			Audience:  audience,
 		},
+	}, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	if signingKeys == nil {
+		tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return tokenClaims.SignedString(jwtSecret)
 	}
 
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenClaims.SignedString(jwtSecret)
+	key := signingKeys.signingKey()
+	tokenClaims := jwt.NewWithClaims(signingKeys.method, claims)
+	tokenClaims.Header["kid"] = key.ID
 
-	return token, err
+	return tokenClaims.SignedString(key.Private)
+}
+
+// GenerateOidcState issue the token that keeps the state of an OpenID
+// Connect login in the browser
+func GenerateOidcState(provider, nonce, verifier string, expire time.Duration) (*OidcStateClaims, string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nowTime := time.Now()
+	claims := &OidcStateClaims{
+		Provider: provider,
+		Nonce:    nonce,
+		Verifier: verifier,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  nowTime.Unix(),
+			ExpiresAt: nowTime.Add(expire).Unix(),
+			Issuer:    settings.AppSetting.JwtIssuer,
+			Audience:  oidcStateAudience(),
+		},
+	}
+
+	token, err := signToken(claims)
+	if err != nil {
+		return nil, "", err
+	}
+	return claims, token, nil
 }
 
 func ParseToken(token string) (*Claims, error) {
-	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, verificationKey)
 
 	if tokenClaims != nil {
 		if claims, ok := tokenClaims.Claims.(*Claims); ok && tokenClaims.Valid {
@@ -48,3 +205,55 @@ func ParseToken(token string) (*Claims, error) {
 
 	return nil, err
 }
+
+func ParseMfaToken(token string) (*Claims, error) {
+	tokenClaims, err := jwt.ParseWithClaims(token, &MfaClaims{}, verificationKey)
+
+	if tokenClaims != nil {
+		if claims, ok := tokenClaims.Claims.(*MfaClaims); ok && tokenClaims.Valid {
+			return &claims.Claims, nil
+		}
+	}
+
+	return nil, err
+}
+
+func ParseOidcState(token string) (*OidcStateClaims, error) {
+	tokenClaims, err := jwt.ParseWithClaims(token, &OidcStateClaims{}, verificationKey)
+
+	if tokenClaims != nil {
+		if claims, ok := tokenClaims.Claims.(*OidcStateClaims); ok && tokenClaims.Valid {
+			return claims, nil
+		}
+	}
+
+	return nil, err
+}
+
+// verificationKey select the key that verifies token: the shared secret for
+// HS256, otherwise the public key named by the kid header
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if signingKeys == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method " + token.Method.Alg())
+		}
+		return jwtSecret, nil
+	}
+
+	if token.Method != signingKeys.method {
+		return nil, errors.New("unexpected signing method " + token.Method.Alg())
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := signingKeys.publicKey(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key " + kid)
+	}
+
+	return key, nil
+}
+
+// IsTokenExpired reports whether err returned by ParseToken means the token has expired
+func IsTokenExpired(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors&jwt.ValidationErrorExpired != 0
+}
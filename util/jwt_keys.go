@@ -0,0 +1,503 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/miaozhang/webservice/logging"
+	"github.com/miaozhang/webservice/settings"
+)
+
+const (
+	rsaKeyBits = 2048
+
+	// keyReloadInterval limit how often an unknown kid triggers a reload of the key directory
+	keyReloadInterval = 10 * time.Second
+	keyRotateInterval = 10 * time.Minute
+	// keyLockTimeout is how long the lock of an instance that died while
+	// generating a key holds the key directory
+	keyLockTimeout = time.Minute
+
+	// kidTimeFormat is the creation time at the start of the generated kids
+	kidTimeFormat = "20060102T150405Z"
+
+	// JwksMaxAge is how long the JWKS may be cached. A new key is published
+	// that long before it signs, so verifiers with a cached JWKS know it.
+	JwksMaxAge = 5 * time.Minute
+)
+
+// SigningKey is one private key in the key directory, its file name is the kid
+type SigningKey struct {
+	ID        string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JSONWebKey is the RFC 7517 representation of a public signing key
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey decode the RSA, EC or Ed25519 public key carried by the JWK
+func (k *JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// keySet hold the asymmetric keys of the configured signing method. The
+// newest key published for JwksMaxAge signs new tokens, older ones are kept
+// to verify the tokens they signed until those have expired.
+type keySet struct {
+	mu         sync.RWMutex
+	dir        string
+	method     jwt.SigningMethod
+	keys       map[string]*SigningKey
+	lastReload time.Time
+}
+
+var signingKeys *keySet
+
+func setupSigningKeys() error {
+	var method jwt.SigningMethod
+	switch settings.AppSetting.JwtSigningMethod {
+	case "", jwt.SigningMethodHS256.Alg():
+		signingKeys = nil
+		return nil
+	case jwt.SigningMethodRS256.Alg():
+		method = jwt.SigningMethodRS256
+	case SigningMethodEd25519.Alg():
+		method = SigningMethodEd25519
+	default:
+		return fmt.Errorf("unsupported JwtSigningMethod %q", settings.AppSetting.JwtSigningMethod)
+	}
+
+	ks := &keySet{
+		dir:    settings.AppSetting.RuntimeRootPath + settings.AppSetting.JwtKeySavePath,
+		method: method,
+	}
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return err
+	}
+	if err := ks.rotate(); err != nil {
+		return err
+	}
+
+	signingKeys = ks
+	go ks.rotateEvery(keyRotateInterval)
+
+	return nil
+}
+
+func (ks *keySet) rotateEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := ks.rotate(); err != nil {
+			logging.Error("util.rotateEvery rotate jwt signing keys fail", err)
+		}
+	}
+}
+
+// rotate reload the key directory, generate the next key when it is due and
+// drop keys that can no longer have valid tokens
+func (ks *keySet) rotate() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.load(); err != nil {
+		return err
+	}
+
+	if ks.needsKey() {
+		if err := ks.generateOnce(); err != nil {
+			return err
+		}
+	}
+
+	ks.prune()
+	return nil
+}
+
+// needsKey tell whether the next key is due: there is no key yet, or the
+// newest is so old that its successor must be published now to sign once
+// JwtKeyRotation has passed
+func (ks *keySet) needsKey() bool {
+	newest := ks.newest()
+	if newest == nil {
+		return true
+	}
+
+	rotation := settings.AppSetting.JwtKeyRotation
+	return rotation > 0 && time.Since(newest.CreatedAt) >= rotation-JwksMaxAge
+}
+
+// generateOnce generate the next key unless another instance sharing the
+// key directory is generating it, its key is then picked up on a later
+// reload. Without any key there is nothing to sign with, so it waits for
+// that instance instead.
+func (ks *keySet) generateOnce() error {
+	for {
+		unlock, ok, err := ks.lock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			defer unlock()
+
+			// the key may have been written while the lock was held elsewhere
+			if err := ks.load(); err != nil {
+				return err
+			}
+			if !ks.needsKey() {
+				return nil
+			}
+			return ks.generate()
+		}
+
+		if len(ks.keys) > 0 {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+		if err := ks.load(); err != nil {
+			return err
+		}
+		if !ks.needsKey() {
+			return nil
+		}
+	}
+}
+
+// lock create the lock file of the key directory, false when another
+// instance holds it. A lock older than keyLockTimeout is removed so the
+// next attempt takes it.
+func (ks *keySet) lock() (func(), bool, error) {
+	file := filepath.Join(ks.dir, ".lock")
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) > keyLockTimeout {
+			os.Remove(file)
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	f.Close()
+
+	return func() { os.Remove(file) }, true, nil
+}
+
+// reload pick up keys written by other instances, at most once per keyReloadInterval
+func (ks *keySet) reload() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if time.Since(ks.lastReload) < keyReloadInterval {
+		return
+	}
+	if err := ks.load(); err != nil {
+		logging.Error("util.reload reload jwt signing keys fail", err)
+	}
+}
+
+func (ks *keySet) load() error {
+	ks.lastReload = time.Now()
+
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey)
+	for _, file := range files {
+		key, err := readSigningKey(file)
+		if err != nil {
+			// e.g. a key dropped in by hand without the creation time in its name
+			logging.Warn("util.load skip jwt signing key", err)
+			continue
+		}
+		if !ks.accepts(key.Private) {
+			continue
+		}
+
+		keys[key.ID] = key
+	}
+
+	ks.keys = keys
+	return nil
+}
+
+func (ks *keySet) newest() *SigningKey {
+	var newest *SigningKey
+	for _, key := range ks.keys {
+		if newest == nil || key.CreatedAt.After(newest.CreatedAt) {
+			newest = key
+		}
+	}
+
+	return newest
+}
+
+func (ks *keySet) accepts(key crypto.Signer) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return ks.method == jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		return ks.method == SigningMethodEd25519
+	}
+
+	return false
+}
+
+func (ks *keySet) generate() error {
+	var private crypto.Signer
+	var err error
+	if ks.method == SigningMethodEd25519 {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	suffix, err := RandomToken(4)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	kid := now.Format(kidTimeFormat) + "-" + suffix
+
+	// written under another name first, so other instances never load half a key
+	tmp, err := ioutil.TempFile(ks.dir, ".key-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(ks.dir, kid+".pem")); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	ks.keys[kid] = &SigningKey{ID: kid, Private: private, CreatedAt: now}
+
+	return nil
+}
+
+// prune remove every key that was replaced by a newer key longer than
+// JwtExpire ago, as no token it signed can still be valid. A key is replaced
+// once its successor has been published for JwksMaxAge.
+func (ks *keySet) prune() {
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	for i := 0; i < len(keys)-1; i++ {
+		if time.Since(keys[i+1].CreatedAt.Add(JwksMaxAge)) <= settings.AppSetting.JwtExpire {
+			break
+		}
+
+		delete(ks.keys, keys[i].ID)
+		os.Remove(filepath.Join(ks.dir, keys[i].ID+".pem"))
+	}
+}
+
+// signingKey return the newest key published for at least JwksMaxAge, or
+// the newest key when none is, as on the first start
+func (ks *keySet) signingKey() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var active *SigningKey
+	for _, key := range ks.keys {
+		if time.Since(key.CreatedAt) >= JwksMaxAge && (active == nil || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+	if active == nil {
+		return ks.newest()
+	}
+
+	return active
+}
+
+func (ks *keySet) publicKey(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+
+	if !ok {
+		ks.reload()
+
+		ks.mu.RLock()
+		key, ok = ks.keys[kid]
+		ks.mu.RUnlock()
+	}
+	if !ok {
+		return nil, false
+	}
+
+	return key.Private.Public(), true
+}
+
+func (ks *keySet) jwks() []JSONWebKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := make([]JSONWebKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: ks.method.Alg()}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool {
+		return jwks[i].Kid < jwks[j].Kid
+	})
+	return jwks
+}
+
+func readSigningKey(file string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", file)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", file, parsed)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(file), ".pem")
+	createdAt, err := kidTime(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	return &SigningKey{
+		ID:        id,
+		Private:   private,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// kidTime return the creation time at the start of a generated kid, the
+// modification time of a key file changes when it is copied or restored
+func kidTime(kid string) (time.Time, error) {
+	i := strings.IndexByte(kid, '-')
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("kid %q doesn't start with its creation time", kid)
+	}
+
+	return time.Parse(kidTimeFormat, kid[:i])
+}
+
+// PublicJWKs return the public keys that verify our tokens, empty when
+// tokens are signed with the shared HS256 secret
+func PublicJWKs() []JSONWebKey {
+	if signingKeys == nil {
+		return []JSONWebKey{}
+	}
+
+	return signingKeys.jwks()
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+// writeTestKey write an Ed25519 key created at createdAt into dir, the file
+// itself is as new as ever
+func writeTestKey(t *testing.T, dir string, createdAt time.Time) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kid := createdAt.UTC().Format(kidTimeFormat) + "-test"
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return kid
+}
+
+func TestKeySetRotate(t *testing.T) {
+	settings.AppSetting.JwtKeyRotation = 24 * time.Hour
+	settings.AppSetting.JwtExpire = time.Hour
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		// ages of the keys in the directory
+		ages []time.Duration
+		// whether another instance holds the lock, and since when
+		lockedFor time.Duration
+		locked    bool
+		// whether the directory also holds files that aren't generated keys
+		foreign  bool
+		wantKeys int
+		// index in ages of the key that signs, -1 for the generated one
+		wantSigning int
+	}{
+		{name: "no key", wantKeys: 1, wantSigning: -1},
+		{name: "current key", ages: []time.Duration{time.Hour}, wantKeys: 1, wantSigning: 0},
+		{name: "next key is published before it is due", ages: []time.Duration{24*time.Hour - JwksMaxAge}, wantKeys: 2, wantSigning: 0},
+		{name: "published key doesn't sign yet", ages: []time.Duration{25 * time.Hour, JwksMaxAge / 2}, wantKeys: 2, wantSigning: 0},
+		{name: "published key signs after JwksMaxAge", ages: []time.Duration{25 * time.Hour, JwksMaxAge}, wantKeys: 2, wantSigning: 1},
+		{name: "replaced key is kept until its tokens expire", ages: []time.Duration{48 * time.Hour, time.Hour}, wantKeys: 2, wantSigning: 1},
+		{name: "replaced key is dropped once its tokens expired", ages: []time.Duration{48 * time.Hour, 2 * time.Hour}, wantKeys: 1, wantSigning: 1},
+		{name: "another instance is generating the next key", ages: []time.Duration{24 * time.Hour}, locked: true, wantKeys: 1, wantSigning: 0},
+		{name: "stale lock", ages: []time.Duration{24 * time.Hour}, locked: true, lockedFor: 2 * keyLockTimeout, wantKeys: 1, wantSigning: 0},
+		{name: "unrecognized files are skipped", ages: []time.Duration{time.Hour}, foreign: true, wantKeys: 1, wantSigning: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "keys")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			var kids []string
+			for _, age := range tt.ages {
+				kids = append(kids, writeTestKey(t, dir, now.Add(-age)))
+			}
+			if tt.foreign {
+				kid := writeTestKey(t, dir, now)
+				if err := os.Rename(filepath.Join(dir, kid+".pem"), filepath.Join(dir, "by-hand.pem")); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, "20200101T000000Z-broken.pem"), []byte("not a key"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			lockFile := filepath.Join(dir, ".lock")
+			if tt.locked {
+				if err := ioutil.WriteFile(lockFile, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+				lockedAt := now.Add(-tt.lockedFor)
+				if err := os.Chtimes(lockFile, lockedAt, lockedAt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ks := &keySet{dir: dir, method: SigningMethodEd25519}
+			if err := ks.rotate(); err != nil {
+				t.Fatalf("rotate() error = %v", err)
+			}
+
+			if len(ks.keys) != tt.wantKeys {
+				t.Errorf("rotate() kept %d keys, want %d", len(ks.keys), tt.wantKeys)
+			}
+			signing := ks.signingKey()
+			if tt.wantSigning >= 0 {
+				if signing.ID != kids[tt.wantSigning] {
+					t.Errorf("signingKey() = %s, want %s", signing.ID, kids[tt.wantSigning])
+				}
+			} else if signing == nil || time.Since(signing.CreatedAt) > time.Minute {
+				t.Errorf("signingKey() = %v, want the generated key", signing)
+			}
+
+			_, err = os.Stat(lockFile)
+			if locked := err == nil; locked != (tt.locked && tt.lockedFor < keyLockTimeout) {
+				t.Errorf("lock file exists = %v after rotate()", locked)
+			}
+		})
+	}
+}
+
+func TestKeySetCreatedAtFromKid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	kid := writeTestKey(t, dir, createdAt)
+
+	key, err := readSigningKey(filepath.Join(dir, kid+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.CreatedAt.Equal(createdAt) {
+		t.Errorf("readSigningKey() CreatedAt = %v, want %v", key.CreatedAt, createdAt)
+	}
+
+	if err := os.Rename(filepath.Join(dir, kid+".pem"), filepath.Join(dir, "custom.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readSigningKey(filepath.Join(dir, "custom.pem")); err == nil {
+		t.Error("readSigningKey() of a kid without creation time succeeded")
+	}
+}
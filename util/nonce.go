@@ -0,0 +1,67 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miaozhang/webservice/common"
+	"github.com/miaozhang/webservice/gredis"
+	"github.com/miaozhang/webservice/settings"
+)
+
+// NonceCache remember the nonces of signed requests so each is accepted once
+type NonceCache interface {
+	// Add remember nonce for ttl, returning false when it was already seen
+	Add(nonce string, ttl time.Duration) (bool, error)
+}
+
+type MemoryNonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time)}
+}
+
+func (m *MemoryNonceCache) Add(nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range m.nonces {
+		if exp.Before(now) {
+			delete(m.nonces, k)
+		}
+	}
+
+	if _, ok := m.nonces[nonce]; ok {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+
+	return true, nil
+}
+
+// RedisNonceCache share the nonces between every instance through Redis
+type RedisNonceCache struct{}
+
+func (r *RedisNonceCache) Add(nonce string, ttl time.Duration) (bool, error) {
+	return gredis.SetNX(cacheKey(common.CACHE_REQUEST_NONCE, nonce), "1", ttl)
+}
+
+var nonceCache NonceCache = NewMemoryNonceCache()
+
+func setupNonceCache() {
+	switch settings.AppSetting.HmacNonceStore {
+	case "redis":
+		nonceCache = &RedisNonceCache{}
+	default:
+		nonceCache = NewMemoryNonceCache()
+	}
+}
+
+// UseNonce accept nonce once within ttl, false means it was replayed
+func UseNonce(nonce string, ttl time.Duration) (bool, error) {
+	return nonceCache.Add(nonce, ttl)
+}
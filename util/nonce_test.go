@@ -0,0 +1,36 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceCache(t *testing.T) {
+	cache := NewMemoryNonceCache()
+
+	steps := []struct {
+		name  string
+		nonce string
+		ttl   time.Duration
+		sleep time.Duration
+		want  bool
+	}{
+		{name: "first use", nonce: "a", ttl: 50 * time.Millisecond, want: true},
+		{name: "replay", nonce: "a", ttl: 50 * time.Millisecond, want: false},
+		{name: "other nonce", nonce: "b", ttl: time.Minute, want: true},
+		{name: "replay after expiry", nonce: "a", ttl: time.Minute, sleep: 100 * time.Millisecond, want: true},
+		{name: "replay of the renewed nonce", nonce: "a", ttl: time.Minute, want: false},
+		{name: "replay of a nonce kept longer", nonce: "b", ttl: time.Minute, want: false},
+	}
+
+	for _, step := range steps {
+		time.Sleep(step.sleep)
+		fresh, err := cache.Add(step.nonce, step.ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fresh != step.want {
+			t.Errorf("%s: Add(%q) = %v, want %v", step.name, step.nonce, fresh, step.want)
+		}
+	}
+}
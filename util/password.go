@@ -0,0 +1,96 @@
+package util
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+// PasswordHasher hashes account passwords and verifies them against stored hashes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hashed, password string) (bool, error)
+	// IsHash reports whether stored was produced by this hasher
+	IsHash(stored string) bool
+	// NeedsRehash reports whether stored should be replaced by a fresh hash
+	NeedsRehash(stored string) bool
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(hashed, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h *BcryptHasher) IsHash(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+func (h *BcryptHasher) NeedsRehash(stored string) bool {
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		return true
+	}
+
+	return cost != h.Cost
+}
+
+var passwordHasher PasswordHasher = &BcryptHasher{Cost: bcrypt.DefaultCost}
+
+func setupPasswordHasher() {
+	cost := settings.AppSetting.PasswordHashCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+
+	passwordHasher = &BcryptHasher{Cost: cost}
+}
+
+// HashPassword hash a password with the configured hasher
+func HashPassword(password string) (string, error) {
+	return passwordHasher.Hash(password)
+}
+
+// VerifyPassword check password against the stored value, which may still be
+// a legacy plaintext password. rehash is true when the stored value should be
+// replaced with HashPassword(password).
+func VerifyPassword(stored, password string) (match bool, rehash bool, err error) {
+	if !passwordHasher.IsHash(stored) {
+		match = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return match, match, nil
+	}
+
+	match, err = passwordHasher.Verify(stored, password)
+	if err != nil || !match {
+		return false, false, err
+	}
+
+	return true, passwordHasher.NeedsRehash(stored), nil
+}
+
+// IsPasswordHashed reports whether stored is a hash rather than a legacy plaintext password
+func IsPasswordHashed(stored string) bool {
+	return passwordHasher.IsHash(stored)
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	passwordHasher = &BcryptHasher{Cost: bcrypt.MinCost}
+
+	current, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := (&BcryptHasher{Cost: bcrypt.MinCost + 1}).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		stored     string
+		password   string
+		wantMatch  bool
+		wantRehash bool
+	}{
+		{"legacy plaintext match", "secret", "secret", true, true},
+		{"legacy plaintext mismatch", "secret", "wrong", false, false},
+		{"legacy empty password", "", "", true, true},
+		{"hash match", current, "secret", true, false},
+		{"hash mismatch", current, "wrong", false, false},
+		{"hash with old cost", stale, "secret", true, true},
+		{"hash with old cost mismatch", stale, "wrong", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := VerifyPassword(tt.stored, tt.password)
+			if err != nil {
+				t.Fatalf("VerifyPassword() error = %v", err)
+			}
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("VerifyPassword() = (%v, %v), want (%v, %v)", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
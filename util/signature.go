@@ -0,0 +1,117 @@
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureScheme is the Authorization scheme of HMAC signed requests:
+//
+//	Authorization: HMAC-SHA256 KeyId=<id>,Timestamp=<unix>,Nonce=<nonce>,Signature=<hex>
+//
+// Signature is the hex HMAC-SHA256 with the shared secret of StringToSign.
+const SignatureScheme = "HMAC-SHA256"
+
+var ErrSignatureHeader = errors.New("malformed signature header")
+
+// SignatureHeader is the parsed Authorization header of a signed request
+type SignatureHeader struct {
+	KeyID     string
+	Timestamp int64
+	Nonce     string
+	Signature string
+}
+
+// ParseSignatureHeader parse the credentials following the scheme name
+func ParseSignatureHeader(credentials string) (*SignatureHeader, error) {
+	var h SignatureHeader
+	for _, part := range strings.Split(credentials, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrSignatureHeader
+		}
+
+		switch kv[0] {
+		case "KeyId":
+			h.KeyID = kv[1]
+		case "Timestamp":
+			ts, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return nil, ErrSignatureHeader
+			}
+			h.Timestamp = ts
+		case "Nonce":
+			h.Nonce = kv[1]
+		case "Signature":
+			h.Signature = kv[1]
+		}
+	}
+
+	if h.KeyID == "" || h.Timestamp == 0 || h.Nonce == "" || len(h.Nonce) > 64 || h.Signature == "" {
+		return nil, ErrSignatureHeader
+	}
+	return &h, nil
+}
+
+// StringToSign build the canonical request the signature covers: the scheme,
+// timestamp, nonce, method, request URI (path and raw query) and the hex
+// SHA-256 of the body, one per line
+func StringToSign(timestamp int64, nonce, method, requestURI string, body []byte) string {
+	digest := sha256.Sum256(body)
+	return strings.Join([]string{
+		SignatureScheme,
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+		strings.ToUpper(method),
+		requestURI,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+}
+
+func ComputeSignature(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compare the signature in constant time
+func VerifySignature(secret, stringToSign, signature string) bool {
+	expected := ComputeSignature(secret, stringToSign)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// SignRequest sign req for the client side, e.g. the batch importer. The
+// body is read and put back so the request can still be sent.
+func SignRequest(req *http.Request, keyID, secret string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	nonce, err := RandomToken(16)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+
+	signature := ComputeSignature(secret, StringToSign(timestamp, nonce, req.Method, req.URL.RequestURI(), body))
+	req.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s,Timestamp=%d,Nonce=%s,Signature=%s",
+		SignatureScheme, keyID, timestamp, nonce, signature))
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStringToSign(t *testing.T) {
+	got := StringToSign(1700000000, "nonce", "post", "/api/v1/articles?b=2&a=1", []byte("{}"))
+	want := "HMAC-SHA256\n1700000000\nnonce\nPOST\n/api/v1/articles?b=2&a=1\n" +
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	if got != want {
+		t.Errorf("StringToSign() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSignatureCanonicalization(t *testing.T) {
+	type request struct {
+		timestamp  int64
+		nonce      string
+		method     string
+		requestURI string
+		body       string
+	}
+	base := request{1700000000, "nonce", "POST", "/api/v1/articles?a=1&b=2", `{"title":"t"}`}
+	baseSignature := ComputeSignature("secret", StringToSign(base.timestamp, base.nonce, base.method, base.requestURI, []byte(base.body)))
+
+	tests := []struct {
+		name     string
+		edit     func(r *request)
+		wantSame bool
+	}{
+		{name: "unchanged", edit: func(r *request) {}, wantSame: true},
+		{name: "method case", edit: func(r *request) { r.method = "post" }, wantSame: true},
+		{name: "timestamp", edit: func(r *request) { r.timestamp++ }},
+		{name: "nonce", edit: func(r *request) { r.nonce = "other" }},
+		{name: "method", edit: func(r *request) { r.method = "PUT" }},
+		{name: "path", edit: func(r *request) { r.requestURI = "/api/v1/articles/1?a=1&b=2" }},
+		{name: "query order", edit: func(r *request) { r.requestURI = "/api/v1/articles?b=2&a=1" }},
+		{name: "query dropped", edit: func(r *request) { r.requestURI = "/api/v1/articles" }},
+		{name: "body", edit: func(r *request) { r.body = `{"title":"u"}` }},
+		{name: "empty body", edit: func(r *request) { r.body = "" }},
+		// a field can't be shifted into its neighbour
+		{name: "nonce moved into the method", edit: func(r *request) { r.nonce, r.method = "nonce\nPOST", "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base
+			tt.edit(&r)
+			signature := ComputeSignature("secret", StringToSign(r.timestamp, r.nonce, r.method, r.requestURI, []byte(r.body)))
+			if same := signature == baseSignature; same != tt.wantSame {
+				t.Errorf("signature unchanged = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	stringToSign := StringToSign(1700000000, "nonce", "GET", "/api/v1/tags", nil)
+	signature := ComputeSignature("secret", stringToSign)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", signature, true},
+		{"upper case hex", "secret", strings.ToUpper(signature), true},
+		{"other secret", "other", signature, false},
+		{"truncated", "secret", signature[:len(signature)-2], false},
+		{"empty", "secret", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, stringToSign, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSignatureHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials string
+		want        *SignatureHeader
+	}{
+		{
+			name:        "valid",
+			credentials: "KeyId=importer,Timestamp=1700000000,Nonce=abc,Signature=def",
+			want:        &SignatureHeader{KeyID: "importer", Timestamp: 1700000000, Nonce: "abc", Signature: "def"},
+		},
+		{
+			name:        "spaces and any order",
+			credentials: "Signature=def, Nonce=abc, Timestamp=1700000000, KeyId=importer",
+			want:        &SignatureHeader{KeyID: "importer", Timestamp: 1700000000, Nonce: "abc", Signature: "def"},
+		},
+		{name: "missing key id", credentials: "Timestamp=1700000000,Nonce=abc,Signature=def"},
+		{name: "missing nonce", credentials: "KeyId=importer,Timestamp=1700000000,Signature=def"},
+		{name: "missing signature", credentials: "KeyId=importer,Timestamp=1700000000,Nonce=abc"},
+		{name: "invalid timestamp", credentials: "KeyId=importer,Timestamp=soon,Nonce=abc,Signature=def"},
+		{name: "nonce too long", credentials: "KeyId=importer,Timestamp=1700000000,Nonce=" + strings.Repeat("a", 65) + ",Signature=def"},
+		{name: "part without value", credentials: "KeyId=importer,Timestamp=1700000000,Nonce,Signature=def"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSignatureHeader(tt.credentials)
+			if tt.want == nil {
+				if err != ErrSignatureHeader {
+					t.Errorf("ParseSignatureHeader() error = %v, want %v", err, ErrSignatureHeader)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSignatureHeader() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("ParseSignatureHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://127.0.0.1:8000/api/v1/articles?a=1", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignRequest(req, "importer", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "body" {
+		t.Errorf("body after SignRequest() = %q, want %q", body, "body")
+	}
+
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != SignatureScheme {
+		t.Fatalf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+	header, err := ParseSignatureHeader(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringToSign := StringToSign(header.Timestamp, header.Nonce, "POST", "/api/v1/articles?a=1", body)
+	if header.KeyID != "importer" || !VerifySignature("secret", stringToSign, header.Signature) {
+		t.Errorf("SignRequest() header %+v doesn't verify", header)
+	}
+}
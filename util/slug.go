@@ -0,0 +1,39 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugify turn s into a lower case slug of at most maxRunes runes. The
+// letters and digits of every script are kept, e.g. "Hello, 世界!" gives
+// "hello-世界"; any other run of characters becomes a single hyphen.
+func Slugify(s string, maxRunes int) string {
+	var b strings.Builder
+	n := 0
+	hyphen := false
+	for _, r := range norm.NFKC.String(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !(unicode.IsMark(r) && n > 0 && !hyphen) {
+			hyphen = true
+			continue
+		}
+
+		if hyphen && n > 0 {
+			if n+2 > maxRunes {
+				break
+			}
+			b.WriteByte('-')
+			n++
+		}
+		if n == maxRunes {
+			break
+		}
+		hyphen = false
+		b.WriteRune(unicode.ToLower(r))
+		n++
+	}
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// the SHA1 test vectors of RFC 6238 appendix B, truncated to our 6 digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func rfc6238Secret() string {
+	return totpEncoding.EncodeToString([]byte("12345678901234567890"))
+}
+
+func TestTotpRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := ValidateTotp(rfc6238Secret(), v.code, time.Unix(v.unix, 0), 0)
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("ValidateTotp(%q at %d) = %d, %v, want %d, true", v.code, v.unix, step, ok, v.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	secret := rfc6238Secret()
+	at := time.Unix(1111111111, 0)
+	now := at.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		lastStep int64
+		wantOk   bool
+	}{
+		{name: "current step", code: "050471", at: at, wantOk: true},
+		{name: "one step late", code: "050471", at: at.Add(totpPeriod * time.Second), wantOk: true},
+		{name: "one step early", code: "050471", at: at.Add(-totpPeriod * time.Second), wantOk: true},
+		{name: "two steps late", code: "050471", at: at.Add(2 * totpPeriod * time.Second)},
+		{name: "two steps early", code: "050471", at: at.Add(-2 * totpPeriod * time.Second)},
+		{name: "replayed step", code: "050471", at: at, lastStep: now},
+		{name: "earlier step used", code: "050471", at: at, lastStep: now - 1, wantOk: true},
+		{name: "wrong code", code: "050472", at: at},
+		{name: "8 digit code", code: "14050471", at: at},
+		{name: "empty code", code: "", at: at},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTotp(secret, tt.code, tt.at, tt.lastStep); ok != tt.wantOk {
+				t.Errorf("ValidateTotp() = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+
+	if _, ok := ValidateTotp("not base32!", "050471", at, 0); ok {
+		t.Error("ValidateTotp() with an invalid secret succeeded")
+	}
+}
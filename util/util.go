@@ -0,0 +1,19 @@
+package util
+
+import (
+	"log"
+
+	"github.com/miaozhang/webservice/settings"
+)
+
+// Setup initialize the util package from the loaded settings
+func Setup() {
+	jwtSecret = []byte(settings.AppSetting.JwtSecret)
+	if err := setupSigningKeys(); err != nil {
+		log.Fatalf("util.Setup, fail to load jwt signing keys: %v", err)
+	}
+	setupPasswordHasher()
+	setupTokenDenylist()
+	setupAttemptCounter()
+	setupNonceCache()
+}